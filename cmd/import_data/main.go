@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"log"
 	"os"
 	"shop/internal/domain"
 	"shop/internal/repository/mysql"
+	"shop/pkg/config"
 	"shop/pkg/database"
 	"strconv"
 )
 
 func main() {
-	db, err := database.NewMySQLConnection()
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.NewMySQLConnection(cfg.Database)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -44,7 +51,7 @@ func main() {
 			Category:    record[4],
 		}
 
-		if err := productRepo.Create(product); err != nil {
+		if err := productRepo.Create(context.Background(), product); err != nil {
 			log.Printf("Error creating product %s: %v", product.Name, err)
 		} else {
 			log.Printf("Created product: %s", product.Name)
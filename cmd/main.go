@@ -1,41 +1,172 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"log/slog"
+	nethttp "net/http"
+	"os"
+	"os/signal"
 	"shop/internal/delivery/http"
+	"shop/internal/domain"
+	"shop/internal/gateway/simulator"
+	"shop/internal/repository"
+	"shop/internal/repository/memory"
 	"shop/internal/repository/mysql"
+	"shop/internal/repository/sqlite"
 	"shop/internal/usecase"
+	"shop/internal/worker"
+	"shop/pkg/config"
 	"shop/pkg/database"
+	"shop/pkg/jwt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take to finish once
+// the server is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	// Initialize database
-	db, err := database.NewMySQLConnection()
+	configPath := flag.String("config", "", "path to a YAML config file (defaults to $CONFIG_FILE)")
+	storage := flag.String("storage", "", "storage backend: mysql, sqlite or memory (overrides the config)")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if *storage != "" {
+		cfg.Storage = *storage
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.Env == config.ProfileProd {
+		gin.SetMode(gin.ReleaseMode)
 	}
-	defer db.Close()
+	jwt.Configure(cfg.JWT.Secret, cfg.JWT.TTL)
 
 	// Initialize repositories
-	userRepo := mysql.NewUserRepository(db)
-	productRepo := mysql.NewProductRepository(db)
-	orderRepo := mysql.NewOrderRepository(db)
-	cartRepo := mysql.NewCartRepository(db)
-	paymentRepo := mysql.NewPaymentRepository(db)
+	var repos repository.Repositories
+	var unitOfWork repository.UnitOfWork
+
+	switch cfg.Storage {
+	case config.StorageMySQL, config.StorageSQLite:
+		db, migrationFiles, err := database.Open(cfg)
+		if err != nil {
+			log.Fatal("Failed to connect to database:", err)
+		}
+		defer db.Close()
+
+		// Refuse to run against a schema that is behind the code
+		migrator, err := database.NewMigrator(db, migrationFiles)
+		if err != nil {
+			log.Fatal("Failed to load migrations:", err)
+		}
+		if err := migrator.EnsureUpToDate(context.Background()); err != nil {
+			log.Fatal(err, " (run `go run ./cmd/migrate up`)")
+		}
+
+		if cfg.Storage == config.StorageMySQL {
+			repos = mysql.NewRepositories(db)
+			unitOfWork = mysql.NewUnitOfWork(db)
+		} else {
+			repos = sqlite.NewRepositories(db)
+			unitOfWork = sqlite.NewUnitOfWork(db)
+		}
+	case config.StorageMemory:
+		// Data lives only as long as the process; meant for demos.
+		store := memory.NewStore()
+		repos = store.Repositories()
+		unitOfWork = memory.NewUnitOfWork(store)
+	}
+
+	// Initialize payment gateway
+	paymentGateway := simulator.New(simulator.Config{
+		Outcome:     simulator.Outcome(cfg.Payment.Simulator.Outcome),
+		Secret:      cfg.Payment.Simulator.Secret,
+		CallbackURL: cfg.Payment.Simulator.CallbackURL,
+		Delay:       cfg.Payment.Simulator.Delay,
+	})
 
 	// Initialize use cases
-	userUsecase := usecase.NewUserUsecase(userRepo)
-	productUsecase := usecase.NewProductUsecase(productRepo)
-	orderUsecase := usecase.NewOrderUsecase(orderRepo, productRepo)
-	cartUsecase := usecase.NewCartUseCase(cartRepo, orderRepo, productRepo, userRepo)
-	paymentUsecase := usecase.NewPaymentUseCase(paymentRepo, cartRepo, orderRepo, productRepo, userRepo)
+	userUsecase := usecase.NewUserUsecase(repos.Users)
+	reservations := usecase.NewReservationService(repos.Reservations, cfg.Payment.ReservationTTL)
+	productUsecase := usecase.NewProductUsecase(repos.Products, reservations)
+	cartUsecase := usecase.NewCartUseCase(repos.Carts, repos.Orders, repos.Products, repos.Users, unitOfWork)
+	paymentUsecase := usecase.NewPaymentUseCase(repos.Payments, repos.Carts, repos.Orders, repos.Products, repos.Users, paymentGateway, unitOfWork, cfg.Payment.LockTTL, reservations)
+	refundUsecase := usecase.NewRefundUsecase(repos.Payments, repos.Refunds, paymentGateway, unitOfWork)
+	orderUsecase := usecase.NewOrderUsecase(repos.Orders, repos.Products, repos.Payments, repos.Users, repos.Shipments, unitOfWork, refundUsecase)
+	shipmentUsecase := usecase.NewShipmentUsecase(repos.Orders, repos.Shipments, unitOfWork)
+	returnUsecase := usecase.NewReturnUsecase(repos.Orders, repos.Payments, repos.Returns, unitOfWork, refundUsecase)
+	addressUsecase := usecase.NewAddressUsecase(repos.Addresses, unitOfWork)
+	checkoutUsecase := usecase.NewCheckoutUsecase(repos.Orders, unitOfWork, paymentUsecase, checkoutPricing(cfg.Checkout))
 
 	// Initialize HTTP handler
-	handler := http.NewHandler(userUsecase, productUsecase, orderUsecase, cartUsecase, paymentUsecase)
+	handler := http.NewHandler(cfg.CORS.AllowedOrigins, userUsecase, productUsecase, orderUsecase, cartUsecase, paymentUsecase, refundUsecase, shipmentUsecase, returnUsecase, addressUsecase, checkoutUsecase)
+
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background workers
+	var workers sync.WaitGroup
+	if cfg.Payment.Reaper.Enabled {
+		logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		reaper := worker.NewPaymentReaper(paymentUsecase, cfg.Payment.Reaper, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			reaper.Run(ctx)
+		}()
+	}
 
 	// Start server
-	log.Println("Server starting on :8080")
-	if err := handler.Router.Run(":8080"); err != nil {
+	server := &nethttp.Server{Addr: cfg.Server.Addr, Handler: handler.Router}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+	log.Printf("Server starting on %s (%s profile, %s storage)", cfg.Server.Addr, cfg.Env, cfg.Storage)
+
+	select {
+	case err := <-serveErr:
 		log.Fatal("Failed to start server:", err)
+	case <-ctx.Done():
+	}
+
+	log.Print("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	// The workers still use the database, which is closed on return.
+	workers.Wait()
+}
+
+// checkoutPricing converts the checkout settings for CheckoutUsecase.
+func checkoutPricing(cfg config.CheckoutConfig) usecase.CheckoutPricing {
+	pricing := usecase.CheckoutPricing{TaxRate: cfg.TaxRate}
+	for _, m := range cfg.ShippingMethods {
+		pricing.ShippingMethods = append(pricing.ShippingMethods, domain.ShippingMethod{
+			Code:     m.Code,
+			Name:     m.Name,
+			Price:    m.Price,
+			FreeOver: m.FreeOver,
+		})
+	}
+	for _, d := range cfg.Discounts {
+		pricing.Discounts = append(pricing.Discounts, domain.Discount{
+			Code:        d.Code,
+			Percent:     d.Percent,
+			Amount:      d.Amount,
+			MinSubtotal: d.MinSubtotal,
+		})
 	}
+	return pricing
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"shop/pkg/config"
+	"shop/pkg/database"
+	"strconv"
+)
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up             apply all pending migrations
+  down [N]       roll back the last N migrations (default 1)
+  status         list migrations and whether they are applied
+  create NAME    write an empty up/down pair to -dir
+
+Every dialect keeps its own migrations in migrations/<storage>; a schema
+change needs a migration with the same version in each of them.
+
+Flags:
+`
+
+func main() {
+	dir := flag.String("dir", "", "directory new migrations are written to (defaults to migrations/<storage>)")
+	storage := flag.String("storage", "", "mysql or sqlite (overrides the config)")
+	configPath := flag.String("config", "", "path to a YAML config file (defaults to $CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if *storage != "" {
+		cfg.Storage = *storage
+	}
+
+	// create only touches the filesystem
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("create requires a migration name")
+		}
+		if *dir == "" {
+			*dir = filepath.Join("migrations", cfg.Storage)
+		}
+		paths, err := database.CreateMigration(*dir, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	db, migrationFiles, err := database.Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrationFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"shop/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{domain.ErrNotFound, http.StatusNotFound, "not_found"},
+	{domain.ErrInsufficientStock, http.StatusConflict, "insufficient_stock"},
+	{domain.ErrCartLocked, http.StatusConflict, "cart_locked"},
+	{domain.ErrInvalidState, http.StatusConflict, "invalid_state"},
+	{domain.ErrConflict, http.StatusConflict, "conflict"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{domain.ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+}
+
+// respondError writes err as an ErrorResponse.
+func respondError(c *gin.Context, err error) {
+	status, resp := errorResponse(c, err)
+	c.AbortWithStatusJSON(status, resp)
+}
+
+// errorResponse maps err to a status code and ErrorResponse. Only messages of
+// domain errors reach the client; anything else is logged and reported as an
+// internal error.
+func errorResponse(c *gin.Context, err error) (int, ErrorResponse) {
+	for _, k := range errorKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+
+		resp := ErrorResponse{Code: k.code, Message: k.kind.Error()}
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			resp.Message = domainErr.Message
+			resp.Details = domainErr.Details
+		}
+		return k.status, resp
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, ErrorResponse{Code: "timeout", Message: "request timed out"}
+	case errors.Is(err, context.Canceled):
+		return http.StatusRequestTimeout, ErrorResponse{Code: "canceled", Message: "request canceled"}
+	default:
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		return http.StatusInternalServerError, ErrorResponse{Code: "internal_error", Message: "internal server error"}
+	}
+}
+
+// respondBindError reports a request body or parameter that failed to parse.
+func respondBindError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Code: "invalid_input", Message: err.Error()})
+}
+
+// respondInvalidID reports a malformed numeric path parameter.
+func respondInvalidID(c *gin.Context, resource string) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Code: "invalid_input", Message: "invalid " + resource + " ID"})
+}
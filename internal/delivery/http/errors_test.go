@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shop/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		// message is what the client is told.
+		message string
+	}{
+		{"not found", domain.NotFound("order"), http.StatusNotFound, "not_found", "order not found"},
+		{"insufficient stock", domain.InsufficientStock(1, 3, 2), http.StatusConflict, "insufficient_stock", "insufficient stock"},
+		{"cart locked", domain.NewError(domain.ErrCartLocked, "cart is locked for payment"), http.StatusConflict, "cart_locked", "cart is locked for payment"},
+		{"invalid state", domain.NewError(domain.ErrInvalidState, "order can no longer be cancelled"), http.StatusConflict, "invalid_state", "order can no longer be cancelled"},
+		{"conflict", domain.NewError(domain.ErrConflict, "username taken"), http.StatusConflict, "conflict", "username taken"},
+		{"forbidden", domain.NewError(domain.ErrForbidden, "authentication required"), http.StatusForbidden, "forbidden", "authentication required"},
+		{"unauthorized", domain.NewError(domain.ErrUnauthorized, "invalid signature"), http.StatusUnauthorized, "unauthorized", "invalid signature"},
+		{"invalid input", domain.NewError(domain.ErrInvalidInput, "quantity must be at least 1"), http.StatusBadRequest, "invalid_input", "quantity must be at least 1"},
+		{"wrapped", fmt.Errorf("loading order: %w", domain.NotFound("order")), http.StatusNotFound, "not_found", "order not found"},
+		{"bare kind", domain.ErrConflict, http.StatusConflict, "conflict", domain.ErrConflict.Error()},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout", "request timed out"},
+		{"canceled", context.Canceled, http.StatusRequestTimeout, "canceled", "request canceled"},
+		{"internal", errors.New("connection refused to 10.0.0.1"), http.StatusInternalServerError, "internal_error", "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			status, resp := errorResponse(c, tt.err)
+			if status != tt.status || resp.Code != tt.code {
+				t.Errorf("errorResponse = %d %s, want %d %s", status, resp.Code, tt.status, tt.code)
+			}
+			if resp.Message != tt.message {
+				t.Errorf("message = %q, want %q", resp.Message, tt.message)
+			}
+			var domainErr *domain.Error
+			if errors.As(tt.err, &domainErr) && len(resp.Details) != len(domainErr.Details) {
+				t.Errorf("details = %v, want %v", resp.Details, domainErr.Details)
+			}
+		})
+	}
+}
@@ -1,30 +1,58 @@
 package http
 
 import (
+	"errors"
+	"expvar"
+	"io"
 	"net/http"
 	"shop/internal/domain"
+	"shop/internal/gateway"
 	"shop/internal/middleware"
 	"shop/internal/usecase"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Deadlines applied to the request context of each route.
+const (
+	readTimeout    = 5 * time.Second
+	writeTimeout   = 10 * time.Second
+	gatewayTimeout = 30 * time.Second
+)
+
 type Handler struct {
-	Router         *gin.Engine
-	userUsecase    *usecase.UserUsecase
-	productUsecase *usecase.ProductUsecase
-	orderUsecase   *usecase.OrderUsecase
-	cartUsecase    *usecase.CartUsecase
+	Router          *gin.Engine
+	userUsecase     *usecase.UserUsecase
+	productUsecase  *usecase.ProductUsecase
+	orderUsecase    *usecase.OrderUsecase
+	cartUsecase     *usecase.CartUsecase
+	paymentUsecase  *usecase.PaymentUsecase
+	refundUsecase   *usecase.RefundUsecase
+	shipmentUsecase *usecase.ShipmentUsecase
+	returnUsecase   *usecase.ReturnUsecase
+	addressUsecase  *usecase.AddressUsecase
+	checkoutUsecase *usecase.CheckoutUsecase
 }
 
-func NewHandler(userUsecase *usecase.UserUsecase, productUsecase *usecase.ProductUsecase, orderUsecase *usecase.OrderUsecase, cartUsecase *usecase.CartUsecase) *Handler {
+func NewHandler(allowedOrigins []string, userUsecase *usecase.UserUsecase, productUsecase *usecase.ProductUsecase, orderUsecase *usecase.OrderUsecase, cartUsecase *usecase.CartUsecase, paymentUsecase *usecase.PaymentUsecase, refundUsecase *usecase.RefundUsecase, shipmentUsecase *usecase.ShipmentUsecase, returnUsecase *usecase.ReturnUsecase, addressUsecase *usecase.AddressUsecase, checkoutUsecase *usecase.CheckoutUsecase) *Handler {
+	router := gin.Default()
+	router.Use(middleware.CORS(allowedOrigins))
+
 	handler := &Handler{
-		Router:         gin.Default(),
-		userUsecase:    userUsecase,
-		productUsecase: productUsecase,
-		orderUsecase:   orderUsecase,
-		cartUsecase:    cartUsecase,
+		Router:          router,
+		userUsecase:     userUsecase,
+		productUsecase:  productUsecase,
+		orderUsecase:    orderUsecase,
+		cartUsecase:     cartUsecase,
+		paymentUsecase:  paymentUsecase,
+		refundUsecase:   refundUsecase,
+		shipmentUsecase: shipmentUsecase,
+		returnUsecase:   returnUsecase,
+		addressUsecase:  addressUsecase,
+		checkoutUsecase: checkoutUsecase,
 	}
 
 	handler.setupRoutes()
@@ -37,60 +65,138 @@ func (h *Handler) setupRoutes() {
 	// Auth routes
 	auth := api.Group("/auth")
 	{
-		auth.POST("/register", h.register)
-		auth.POST("/login", h.login)
+		auth.POST("/register", middleware.Timeout(writeTimeout), h.register)
+		auth.POST("/login", middleware.Timeout(readTimeout), h.login)
 	}
 
 	// User routes
 	users := api.Group("/users")
 	users.Use(middleware.AuthMiddleware())
 	{
-		users.GET("/profile", h.getProfile)
+		users.GET("/profile", middleware.Timeout(readTimeout), h.getProfile)
+		users.GET("/addresses", middleware.Timeout(readTimeout), h.getAddresses)
+		users.POST("/addresses", middleware.Timeout(writeTimeout), h.createAddress)
+		users.GET("/addresses/:id", middleware.Timeout(readTimeout), h.getAddress)
+		users.PUT("/addresses/:id", middleware.Timeout(writeTimeout), h.updateAddress)
+		users.DELETE("/addresses/:id", middleware.Timeout(writeTimeout), h.deleteAddress)
 	}
 
 	// Product routes
 	products := api.Group("/products")
 	{
-		products.GET("", h.getProducts)
-		products.GET("/:id", h.getProduct)
+		products.GET("", middleware.Timeout(readTimeout), h.getProducts)
+		products.GET("/:id", middleware.Timeout(readTimeout), h.getProduct)
 	}
 
 	// Admin product routes
 	adminProducts := api.Group("/admin/products")
 	adminProducts.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
 	{
-		adminProducts.POST("", h.createProduct)
-		adminProducts.PUT("/:id", h.updateProduct)
-		adminProducts.DELETE("/:id", h.deleteProduct)
+		adminProducts.POST("", middleware.Timeout(writeTimeout), h.createProduct)
+		adminProducts.PUT("/:id", middleware.Timeout(writeTimeout), h.updateProduct)
+		adminProducts.DELETE("/:id", middleware.Timeout(writeTimeout), h.deleteProduct)
 	}
 
 	// Order routes
 	orders := api.Group("/orders")
 	orders.Use(middleware.AuthMiddleware())
 	{
-		orders.POST("", h.createOrder)
-		orders.GET("", h.getUserOrders)
-		orders.GET("/:id", h.getOrder)
+		orders.POST("", middleware.Timeout(writeTimeout), h.createOrder)
+		orders.GET("", middleware.Timeout(readTimeout), h.getUserOrders)
+		orders.GET("/:id", middleware.Timeout(readTimeout), h.getOrder)
+		orders.POST("/:id/cancel", middleware.Timeout(gatewayTimeout), h.cancelOrder)
+		orders.GET("/:id/shipments", middleware.Timeout(readTimeout), h.getShipments)
+		orders.POST("/:id/returns", middleware.Timeout(writeTimeout), h.requestReturn)
+		orders.GET("/:id/returns", middleware.Timeout(readTimeout), h.getOrderReturns)
+	}
+
+	// Return routes
+	returns := api.Group("/returns")
+	returns.Use(middleware.AuthMiddleware())
+	{
+		returns.GET("", middleware.Timeout(readTimeout), h.getUserReturns)
+		returns.GET("/:id", middleware.Timeout(readTimeout), h.getReturn)
+		returns.POST("/:id/cancel", middleware.Timeout(writeTimeout), h.cancelReturn)
+	}
+
+	// Admin order routes
+	adminOrders := api.Group("/admin/orders")
+	adminOrders.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminOrders.GET("", middleware.Timeout(readTimeout), h.listOrders)
+		adminOrders.GET("/:id", middleware.Timeout(readTimeout), h.getOrderDetail)
+		adminOrders.PATCH("/:id/status", middleware.Timeout(gatewayTimeout), h.updateOrderStatus)
+		adminOrders.POST("/status", middleware.Timeout(gatewayTimeout), h.bulkUpdateOrderStatus)
+		adminOrders.POST("/:id/shipments", middleware.Timeout(writeTimeout), h.createShipment)
+	}
+
+	// Admin shipment routes
+	adminShipments := api.Group("/admin/shipments")
+	adminShipments.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminShipments.PATCH("/:id", middleware.Timeout(writeTimeout), h.updateShipment)
+	}
+
+	// Admin return routes
+	adminReturns := api.Group("/admin/returns")
+	adminReturns.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminReturns.GET("", middleware.Timeout(readTimeout), h.listReturns)
+		adminReturns.PATCH("/:id/status", middleware.Timeout(gatewayTimeout), h.updateReturnStatus)
 	}
 
 	cart := api.Group("/cart")
 	cart.Use(middleware.AuthMiddleware())
 	{
-		cart.POST("", h.createCart)
-		//cart.GET("", h.getUserOrders)
+		cart.POST("", middleware.Timeout(writeTimeout), h.createCart)
+		cart.GET("", middleware.Timeout(readTimeout), h.getCart)
+		cart.DELETE("", middleware.Timeout(writeTimeout), h.clearCart)
+		cart.PATCH("/items/:product_id", middleware.Timeout(writeTimeout), h.updateCartItem)
+		cart.DELETE("/items/:product_id", middleware.Timeout(writeTimeout), h.deleteCartItem)
 	}
+
+	checkout := api.Group("/checkout")
+	checkout.Use(middleware.AuthMiddleware())
+	{
+		checkout.POST("", middleware.Timeout(gatewayTimeout), h.checkout)
+		checkout.GET("/shipping-methods", middleware.Timeout(readTimeout), h.getShippingMethods)
+	}
+
+	// Payment gateway callback, authenticated by its HMAC signature
+	api.POST("/payments/callback", middleware.Timeout(writeTimeout), h.paymentCallback)
+
+	// Payment routes
+	payments := api.Group("/payments")
+	payments.Use(middleware.AuthMiddleware())
+	{
+		payments.POST("", middleware.Timeout(gatewayTimeout), h.initiatePayment)
+		payments.GET("", middleware.Timeout(readTimeout), h.getUserPayments)
+		payments.GET("/:id", middleware.Timeout(readTimeout), h.getPayment)
+		payments.POST("/:id/cancel", middleware.Timeout(writeTimeout), h.cancelPayment)
+		payments.GET("/:id/refunds", middleware.Timeout(readTimeout), h.getRefunds)
+	}
+
+	// Admin payment routes
+	adminPayments := api.Group("/admin/payments")
+	adminPayments.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminPayments.POST("/:id/refunds", middleware.Timeout(gatewayTimeout), h.refundPayment)
+	}
+
+	// Runtime metrics published through expvar (admin only)
+	api.GET("/admin/metrics", middleware.AuthMiddleware(), middleware.AdminMiddleware(), gin.WrapH(expvar.Handler()))
 }
 
 func (h *Handler) register(c *gin.Context) {
 	var req domain.UserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	user, err := h.userUsecase.Register(&req)
+	user, err := h.userUsecase.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -100,13 +206,13 @@ func (h *Handler) register(c *gin.Context) {
 func (h *Handler) login(c *gin.Context) {
 	var req domain.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	response, err := h.userUsecase.Login(&req)
+	response, err := h.userUsecase.Login(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -116,26 +222,106 @@ func (h *Handler) login(c *gin.Context) {
 func (h *Handler) getProfile(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	user, err := h.userUsecase.GetProfile(userID.(uint))
+	user, err := h.userUsecase.GetProfile(c.Request.Context(), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
+// Address handlers
+func (h *Handler) createAddress(c *gin.Context) {
+	var req domain.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	address, err := h.addressUsecase.CreateAddress(c.Request.Context(), principal(c).UserID, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"address": address})
+}
+
+func (h *Handler) getAddresses(c *gin.Context) {
+	addresses, err := h.addressUsecase.GetAddresses(c.Request.Context(), principal(c).UserID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addresses": addresses})
+}
+
+func (h *Handler) getAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "address")
+		return
+	}
+
+	address, err := h.addressUsecase.GetAddress(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"address": address})
+}
+
+func (h *Handler) updateAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "address")
+		return
+	}
+
+	var req domain.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	address, err := h.addressUsecase.UpdateAddress(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"address": address})
+}
+
+func (h *Handler) deleteAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "address")
+		return
+	}
+
+	if err := h.addressUsecase.DeleteAddress(c.Request.Context(), principal(c), uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
+
 // Product handlers
 func (h *Handler) createProduct(c *gin.Context) {
 	var req domain.ProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	product, err := h.productUsecase.CreateProduct(&req)
+	product, err := h.productUsecase.CreateProduct(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -146,13 +332,13 @@ func (h *Handler) getProduct(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondInvalidID(c, "product")
 		return
 	}
 
-	product, err := h.productUsecase.GetProduct(uint(id))
+	product, err := h.productUsecase.GetProduct(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		respondError(c, err)
 		return
 	}
 
@@ -170,9 +356,9 @@ func (h *Handler) getProducts(c *gin.Context) {
 		limit = 10
 	}
 
-	products, err := h.productUsecase.GetProducts(page, limit)
+	products, err := h.productUsecase.GetProducts(c.Request.Context(), page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -183,19 +369,19 @@ func (h *Handler) updateProduct(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondInvalidID(c, "product")
 		return
 	}
 
 	var req domain.ProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	product, err := h.productUsecase.UpdateProduct(uint(id), &req)
+	product, err := h.productUsecase.UpdateProduct(c.Request.Context(), uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -206,12 +392,12 @@ func (h *Handler) deleteProduct(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondInvalidID(c, "product")
 		return
 	}
 
-	if err := h.productUsecase.DeleteProduct(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.productUsecase.DeleteProduct(c.Request.Context(), uint(id)); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -224,13 +410,13 @@ func (h *Handler) createOrder(c *gin.Context) {
 
 	var req domain.OrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	order, err := h.orderUsecase.CreateOrder(userID.(uint), &req)
+	order, err := h.orderUsecase.CreateOrder(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -241,13 +427,36 @@ func (h *Handler) getOrder(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		respondInvalidID(c, "order")
+		return
+	}
+
+	order, err := h.orderUsecase.GetOrder(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	order, err := h.orderUsecase.GetOrder(uint(id))
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+func (h *Handler) cancelOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		respondInvalidID(c, "order")
+		return
+	}
+
+	// The body is optional
+	var req domain.CancelOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	order, err := h.orderUsecase.CancelOrder(c.Request.Context(), principal(c), uint(id), req.Reason)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -266,29 +475,627 @@ func (h *Handler) getUserOrders(c *gin.Context) {
 		limit = 10
 	}
 
-	orders, err := h.orderUsecase.GetUserOrders(userID.(uint), page, limit)
+	orders, err := h.orderUsecase.GetUserOrders(c.Request.Context(), userID.(uint), page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"orders": orders})
 }
 
+// Admin order handlers
+func (h *Handler) listOrders(c *gin.Context) {
+	filter, err := orderFilter(c)
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	orders, total, err := h.orderUsecase.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": orders,
+		"total":  total,
+		"page":   filter.Offset/filter.Limit + 1,
+		"limit":  filter.Limit,
+	})
+}
+
+// orderFilter reads the admin order list query: status, user_id, from and to
+// (YYYY-MM-DD, both inclusive), min_total, max_total, sort (id, created_at or
+// total, prefixed with - for descending), page and limit.
+func orderFilter(c *gin.Context) (domain.OrderFilter, error) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := domain.OrderFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, errors.New("invalid user_id")
+		}
+		filter.UserID = uint(userID)
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+		days int
+	}{
+		{"from", &filter.CreatedFrom, 0},
+		// to includes the whole day
+		{"to", &filter.CreatedTo, 1},
+	} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", v, time.UTC)
+		if err != nil {
+			return filter, errors.New("invalid " + p.name + ", expected YYYY-MM-DD")
+		}
+		day = day.AddDate(0, 0, p.days)
+		*p.dest = &day
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **float64
+	}{
+		{"min_total", &filter.MinTotal},
+		{"max_total", &filter.MaxTotal},
+	} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		total, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return filter, errors.New("invalid " + p.name)
+		}
+		*p.dest = &total
+	}
+
+	sort := c.DefaultQuery("sort", "-"+domain.OrderSortCreatedAt)
+	if strings.HasPrefix(sort, "-") {
+		filter.Descending = true
+		sort = sort[1:]
+	}
+	switch sort {
+	case domain.OrderSortID, domain.OrderSortCreatedAt, domain.OrderSortTotal:
+		filter.SortBy = sort
+	default:
+		return filter, errors.New("invalid sort, expected id, created_at or total")
+	}
+
+	return filter, nil
+}
+
+func (h *Handler) getOrderDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	order, customer, err := h.orderUsecase.GetOrderDetail(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order, "customer": customer})
+}
+
+func (h *Handler) updateOrderStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	var req domain.UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	order, err := h.orderUsecase.UpdateOrderStatus(c.Request.Context(), principal(c), uint(id), req.Status, req.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// bulkOrderResult is the outcome of one order of a bulk status change.
+type bulkOrderResult struct {
+	OrderID uint           `json:"order_id"`
+	Updated bool           `json:"updated"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+func (h *Handler) bulkUpdateOrderStatus(c *gin.Context) {
+	var req domain.BulkOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	errs := h.orderUsecase.BulkUpdateOrderStatus(c.Request.Context(), principal(c), req.OrderIDs, req.Status, req.Reason)
+
+	results := make([]bulkOrderResult, len(req.OrderIDs))
+	updated := 0
+	for i, id := range req.OrderIDs {
+		results[i] = bulkOrderResult{OrderID: id, Updated: errs[i] == nil}
+		if errs[i] != nil {
+			_, resp := errorResponse(c, errs[i])
+			results[i].Error = &resp
+			continue
+		}
+		updated++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "updated": updated, "failed": len(results) - updated})
+}
+
+// Shipment handlers
+func (h *Handler) createShipment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	var req domain.CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	shipment, err := h.shipmentUsecase.CreateShipment(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"shipment": shipment})
+}
+
+func (h *Handler) updateShipment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "shipment")
+		return
+	}
+
+	var req domain.UpdateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	shipment, err := h.shipmentUsecase.UpdateShipment(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipment": shipment})
+}
+
+func (h *Handler) getShipments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	shipments, err := h.shipmentUsecase.GetShipments(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipments": shipments})
+}
+
+// Return handlers
+func (h *Handler) requestReturn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	var req domain.CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ret, err := h.returnUsecase.RequestReturn(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"return": ret})
+}
+
+func (h *Handler) getOrderReturns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "order")
+		return
+	}
+
+	returns, err := h.returnUsecase.GetOrderReturns(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+func (h *Handler) getUserReturns(c *gin.Context) {
+	filter := returnFilter(c)
+	filter.UserID = principal(c).UserID
+
+	returns, err := h.returnUsecase.ListReturns(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+func (h *Handler) getReturn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "return")
+		return
+	}
+
+	ret, err := h.returnUsecase.GetReturn(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return": ret})
+}
+
+func (h *Handler) cancelReturn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "return")
+		return
+	}
+
+	ret, err := h.returnUsecase.CancelReturn(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return": ret})
+}
+
+func (h *Handler) listReturns(c *gin.Context) {
+	filter := returnFilter(c)
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			respondBindError(c, errors.New("invalid user_id"))
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+
+	returns, err := h.returnUsecase.ListReturns(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+// returnFilter reads the status, page and limit query parameters of a return
+// list.
+func returnFilter(c *gin.Context) domain.ReturnFilter {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	return domain.ReturnFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+}
+
+func (h *Handler) updateReturnStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "return")
+		return
+	}
+
+	var req domain.UpdateReturnStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ret, err := h.returnUsecase.UpdateReturnStatus(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return": ret})
+}
+
 func (h *Handler) createCart(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+	p := principal(c)
 
 	var req domain.RequestCart
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	cart, err := h.cartUsecase.CreateCart(c.Request.Context(), p, p.UserID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"cart": cart})
+}
+
+func (h *Handler) getCart(c *gin.Context) {
+	p := principal(c)
+
+	cart, err := h.cartUsecase.GetCart(c.Request.Context(), p, p.UserID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
+}
+
+func (h *Handler) updateCartItem(c *gin.Context) {
+	p := principal(c)
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "product")
+		return
+	}
+
+	var req domain.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	cart, err := h.cartUsecase.UpdateCartItem(c.Request.Context(), p, p.UserID, uint(productID), req.Quantity)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
+}
+
+func (h *Handler) deleteCartItem(c *gin.Context) {
+	p := principal(c)
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "product")
+		return
+	}
+
+	cart, err := h.cartUsecase.RemoveCartItem(c.Request.Context(), p, p.UserID, uint(productID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": cart})
+}
+
+func (h *Handler) clearCart(c *gin.Context) {
+	p := principal(c)
+
+	if err := h.cartUsecase.ClearCart(c.Request.Context(), p, p.UserID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart cleared successfully"})
+}
+
+// Checkout handlers
+func (h *Handler) checkout(c *gin.Context) {
+	var req domain.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	checkout, err := h.checkoutUsecase.Checkout(c.Request.Context(), principal(c).UserID, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"checkout": checkout})
+}
+
+func (h *Handler) getShippingMethods(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"shipping_methods": h.checkoutUsecase.ShippingMethods()})
+}
+
+// Payment handlers
+func (h *Handler) initiatePayment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req domain.PaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	payment, err := h.paymentUsecase.InitiatePayment(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": payment})
+}
+
+func (h *Handler) getPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "payment")
+		return
+	}
+
+	payment, err := h.paymentUsecase.GetPayment(c.Request.Context(), principal(c), uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+func (h *Handler) getUserPayments(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	payments, err := h.paymentUsecase.GetUserPayments(c.Request.Context(), userID.(uint), page, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": payments})
+}
+
+func (h *Handler) cancelPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "payment")
+		return
+	}
+
+	if err := h.paymentUsecase.CancelPayment(c.Request.Context(), principal(c), uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment cancelled successfully"})
+}
+
+func (h *Handler) refundPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "payment")
+		return
+	}
+
+	var req domain.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	refund, err := h.refundUsecase.RefundPayment(c.Request.Context(), principal(c), uint(id), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"refund": refund})
+}
+
+func (h *Handler) getRefunds(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondInvalidID(c, "payment")
 		return
 	}
 
-	err := h.cartUsecase.CreateCart(userID.(uint), req)
+	refunds, err := h.refundUsecase.GetRefunds(c.Request.Context(), principal(c), uint(id))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"added": "ok"})
+	c.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
+
+func (h *Handler) paymentCallback(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err = h.paymentUsecase.HandleCallback(c.Request.Context(), payload, c.GetHeader(gateway.SignatureHeader))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Callback processed"})
+}
+
+// principal returns the caller that AuthMiddleware authenticated.
+func principal(c *gin.Context) domain.Principal {
+	return domain.Principal{
+		UserID: c.GetUint("user_id"),
+		Role:   c.GetString("user_role"),
+	}
 }
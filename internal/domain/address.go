@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Address kinds. A customer has at most one default address of each kind,
+// and an order keeps a copy of one address of each kind.
+const (
+	AddressKindShipping = "shipping"
+	AddressKindBilling  = "billing"
+)
+
+// PostalAddress is where a parcel or an invoice goes.
+type PostalAddress struct {
+	FullName   string `json:"full_name" db:"full_name"`
+	Phone      string `json:"phone" db:"phone"`
+	Line1      string `json:"line1" db:"line1"`
+	Line2      string `json:"line2" db:"line2"`
+	City       string `json:"city" db:"city"`
+	State      string `json:"state" db:"state"`
+	PostalCode string `json:"postal_code" db:"postal_code"`
+	Country    string `json:"country" db:"country"` // ISO 3166-1 alpha-2
+}
+
+// Address is an entry of a customer's address book.
+type Address struct {
+	ID     uint   `json:"id" db:"id"`
+	UserID uint   `json:"user_id" db:"user_id"`
+	Label  string `json:"label" db:"label"`
+	PostalAddress
+	DefaultShipping bool      `json:"default_shipping" db:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing" db:"default_billing"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OrderAddress is the copy of an address taken when an order is placed, so
+// that later edits of the address book do not change the order. AddressID is
+// the entry it was copied from, until that entry is deleted.
+type OrderAddress struct {
+	ID        uint   `json:"id" db:"id"`
+	OrderID   uint   `json:"order_id" db:"order_id"`
+	Kind      string `json:"kind" db:"kind"` // shipping, billing
+	AddressID *uint  `json:"address_id,omitempty" db:"address_id"`
+	PostalAddress
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AddressRequest creates or replaces an address book entry. The first
+// address of a customer becomes the default of both kinds.
+type AddressRequest struct {
+	Label           string `json:"label" binding:"max=50"`
+	FullName        string `json:"full_name" binding:"required,max=100"`
+	Phone           string `json:"phone" binding:"max=30"`
+	Line1           string `json:"line1" binding:"required,max=255"`
+	Line2           string `json:"line2" binding:"max=255"`
+	City            string `json:"city" binding:"required,max=100"`
+	State           string `json:"state" binding:"max=100"`
+	PostalCode      string `json:"postal_code" binding:"required,max=20"`
+	Country         string `json:"country" binding:"required,len=2,alpha"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
+// PostalAddress returns the postal fields of req, with the country code in
+// upper case.
+func (req *AddressRequest) PostalAddress() PostalAddress {
+	return PostalAddress{
+		FullName:   req.FullName,
+		Phone:      req.Phone,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		City:       req.City,
+		State:      req.State,
+		PostalCode: req.PostalCode,
+		Country:    strings.ToUpper(req.Country),
+	}
+}
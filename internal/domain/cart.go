@@ -12,5 +12,12 @@ type CartItems struct {
 }
 
 type RequestCart struct {
-	Items  []CartItems `json:"items"`
+	Items []CartItems `json:"items"`
+	// Replace sets the quantities of products already in the cart instead of
+	// adding to them.
+	Replace bool `json:"replace,omitempty"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
 }
@@ -0,0 +1,64 @@
+package domain
+
+// ShippingMethod is a way of delivering an order that customers pick at
+// checkout.
+type ShippingMethod struct {
+	Code  string  `json:"code"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+	// FreeOver waives Price for orders whose discounted subtotal reaches it.
+	// Zero never waives it.
+	FreeOver float64 `json:"free_over,omitempty"`
+}
+
+// Discount is a code customers can enter at checkout. It takes Percent off
+// the subtotal, or Amount when Percent is zero, once the subtotal reaches
+// MinSubtotal.
+type Discount struct {
+	Code        string  `json:"code"`
+	Percent     float64 `json:"percent,omitempty"`
+	Amount      float64 `json:"amount,omitempty"`
+	MinSubtotal float64 `json:"min_subtotal,omitempty"`
+}
+
+// CheckoutRequest turns the cart into an order and starts paying for it. The
+// addresses are resolved as for OrderRequest, but a shipping address is
+// required.
+type CheckoutRequest struct {
+	PaymentMethod     string `json:"payment_method" binding:"required"`
+	ShippingMethod    string `json:"shipping_method" binding:"required"`
+	ShippingAddressID *uint  `json:"shipping_address_id"`
+	BillingAddressID  *uint  `json:"billing_address_id"`
+	DiscountCode      string `json:"discount_code" binding:"max=50"`
+}
+
+// CheckoutLine is a cart line priced at checkout. CartPrice is the price the
+// product had when it was put in the cart.
+type CheckoutLine struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+	CartPrice   float64 `json:"cart_price"`
+	Total       float64 `json:"total"`
+}
+
+// Checkout summarises a checkout: the order awaiting payment, the payment
+// started for it and how its total was reached. Total is Subtotal less
+// Discount plus Shipping and Tax.
+type Checkout struct {
+	Order   *Order         `json:"order"`
+	Payment *Payment       `json:"payment"`
+	Items   []CheckoutLine `json:"items"`
+	// Repriced is set when a product's price changed since it was put in the
+	// cart; the order uses the current price.
+	Repriced       bool           `json:"repriced"`
+	ShippingMethod ShippingMethod `json:"shipping_method"`
+	DiscountCode   string         `json:"discount_code,omitempty"`
+	Subtotal       float64        `json:"subtotal"`
+	Discount       float64        `json:"discount"`
+	Shipping       float64        `json:"shipping"`
+	TaxRate        float64        `json:"tax_rate"`
+	Tax            float64        `json:"tax"`
+	Total          float64        `json:"total"`
+}
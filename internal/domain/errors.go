@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Repositories and usecases return these, usually wrapped in an
+// *Error, and the HTTP layer maps each kind to a status code.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrForbidden         = errors.New("forbidden")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrInvalidState      = errors.New("invalid state")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrCartLocked        = errors.New("cart is locked")
+)
+
+// Error is a domain error with a message that is safe to show to clients.
+// Err keeps the underlying cause for logs only.
+type Error struct {
+	Kind    error
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// NotFound reports a missing resource, e.g. NotFound("product").
+func NotFound(resource string) *Error {
+	return NewError(ErrNotFound, resource+" not found")
+}
+
+// InsufficientStock reports that a product cannot cover a requested quantity.
+func InsufficientStock(productID uint, requested, available int) *Error {
+	return NewError(ErrInsufficientStock, "insufficient stock").
+		WithDetail("product_id", productID).
+		WithDetail("requested", requested).
+		WithDetail("available", available)
+}
+
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Wrap records the underlying cause of e.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
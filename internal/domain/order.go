@@ -3,13 +3,93 @@ package domain
 import "time"
 
 type Order struct {
-    ID         uint        `json:"id" db:"id"`
-    UserID     uint        `json:"user_id" db:"user_id"`
-    Total      float64     `json:"total" db:"total"`
-    Status     string      `json:"status" db:"status"`
-    CreatedAt  time.Time   `json:"created_at" db:"created_at"`
-    UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
-    Items      []OrderItem `json:"items"`
+    ID              uint                 `json:"id" db:"id"`
+    UserID          uint                 `json:"user_id" db:"user_id"`
+    Total           float64              `json:"total" db:"total"`
+    Status          string               `json:"status" db:"status"`
+    // How a checkout priced the order; Total is what was charged. Orders
+    // placed without a checkout leave these empty.
+    ShippingMethod  string               `json:"shipping_method,omitempty" db:"shipping_method"`
+    ShippingCost    float64              `json:"shipping_cost" db:"shipping_cost"`
+    DiscountCode    string               `json:"discount_code,omitempty" db:"discount_code"`
+    Discount        float64              `json:"discount" db:"discount"`
+    Tax             float64              `json:"tax" db:"tax"`
+    CreatedAt       time.Time            `json:"created_at" db:"created_at"`
+    UpdatedAt       time.Time            `json:"updated_at" db:"updated_at"`
+    Items           []OrderItem          `json:"items"`
+    ShippingAddress *OrderAddress        `json:"shipping_address,omitempty"`
+    BillingAddress  *OrderAddress        `json:"billing_address,omitempty"`
+    Payments        []Payment            `json:"payments,omitempty"`
+    // Shipments carry the tracking details of the order's parcels.
+    Shipments       []Shipment           `json:"shipments,omitempty"`
+    // History is the order's status timeline, oldest first.
+    History         []OrderStatusHistory `json:"history,omitempty"`
+}
+
+// Order lifecycle. Statuses only change along orderTransitions, and every
+// change is recorded in the order's status history.
+const (
+    OrderStatusPending           = "pending"
+    OrderStatusAwaitingPayment   = "awaiting_payment"
+    OrderStatusPaid              = "paid"
+    OrderStatusProcessing        = "processing"
+    OrderStatusShipped           = "shipped"
+    OrderStatusDelivered         = "delivered"
+    OrderStatusCancelled         = "cancelled"
+    // OrderStatusPartiallyRefunded and OrderStatusRefunded are derived from
+    // the refunds made against an order.
+    OrderStatusPartiallyRefunded = "partially_refunded"
+    OrderStatusRefunded          = "refunded"
+)
+
+var orderTransitions = map[string][]string{
+    OrderStatusPending:           {OrderStatusAwaitingPayment, OrderStatusPaid, OrderStatusCancelled},
+    OrderStatusAwaitingPayment:   {OrderStatusPaid, OrderStatusCancelled},
+    OrderStatusPaid:              {OrderStatusProcessing, OrderStatusShipped, OrderStatusCancelled, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+    OrderStatusProcessing:        {OrderStatusShipped, OrderStatusCancelled, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+    OrderStatusShipped:           {OrderStatusDelivered, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+    OrderStatusDelivered:         {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+    // A partly refunded order is still fulfilled for the items it kept.
+    OrderStatusPartiallyRefunded: {OrderStatusProcessing, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded},
+    OrderStatusCancelled:         {},
+    OrderStatusRefunded:          {},
+}
+
+// IsOrderStatus reports whether status is part of the order lifecycle.
+func IsOrderStatus(status string) bool {
+    _, ok := orderTransitions[status]
+    return ok
+}
+
+// CanTransitionOrder reports whether an order may move from status from to
+// status to.
+func CanTransitionOrder(from, to string) bool {
+    for _, next := range orderTransitions[from] {
+        if next == to {
+            return true
+        }
+    }
+    return false
+}
+
+// Who changed an order's status.
+const (
+    ActorSystem   = "system"
+    ActorCustomer = "customer"
+    ActorAdmin    = "admin"
+)
+
+// OrderStatusHistory records one status change of an order. FromStatus is
+// empty for the entry written when the order is created.
+type OrderStatusHistory struct {
+    ID         uint      `json:"id" db:"id"`
+    OrderID    uint      `json:"order_id" db:"order_id"`
+    FromStatus string    `json:"from_status" db:"from_status"`
+    ToStatus   string    `json:"to_status" db:"to_status"`
+    Actor      string    `json:"actor" db:"actor"`
+    ChangedBy  *uint     `json:"changed_by,omitempty" db:"changed_by"`
+    Reason     string    `json:"reason" db:"reason"`
+    CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 type OrderItem struct {
@@ -21,8 +101,49 @@ type OrderItem struct {
     Product   Product `json:"product"`
 }
 
+// OrderRequest places an order. The addresses default to the customer's
+// default shipping and billing addresses, and billing to shipping.
 type OrderRequest struct {
-    Items []OrderItemRequest `json:"items" binding:"required,dive"`
+    Items             []OrderItemRequest `json:"items" binding:"required,dive"`
+    ShippingAddressID *uint              `json:"shipping_address_id"`
+    BillingAddressID  *uint              `json:"billing_address_id"`
+}
+
+// OrderFilter selects orders for the admin order list. Zero fields do not
+// filter. CreatedTo is exclusive.
+type OrderFilter struct {
+    Status      string
+    UserID      uint
+    CreatedFrom *time.Time
+    CreatedTo   *time.Time
+    MinTotal    *float64
+    MaxTotal    *float64
+    SortBy      string // id, created_at or total
+    Descending  bool
+    Limit       int
+    Offset      int
+}
+
+// Columns the admin order list can be sorted by.
+const (
+    OrderSortID        = "id"
+    OrderSortCreatedAt = "created_at"
+    OrderSortTotal     = "total"
+)
+
+type UpdateOrderStatusRequest struct {
+    Status string `json:"status" binding:"required"`
+    Reason string `json:"reason" binding:"max=255"`
+}
+
+type BulkOrderStatusRequest struct {
+    OrderIDs []uint `json:"order_ids" binding:"required,min=1,max=100"`
+    Status   string `json:"status" binding:"required"`
+    Reason   string `json:"reason" binding:"max=255"`
+}
+
+type CancelOrderRequest struct {
+    Reason string `json:"reason" binding:"max=255"`
 }
 
 type OrderItemRequest struct {
@@ -3,19 +3,42 @@ package domain
 import "time"
 
 type Payment struct {
-	ID                   uint      `json:"id" db:"id"`
-	UserID               uint      `json:"user_id" db:"user_id"`
+	ID     uint `json:"id" db:"id"`
+	UserID uint `json:"user_id" db:"user_id"`
+	// OrderID is the order the payment paid for, set once it is created.
+	OrderID              *uint     `json:"order_id" db:"order_id"`
+	ShippingAddressID    *uint     `json:"shipping_address_id,omitempty" db:"shipping_address_id"`
+	BillingAddressID     *uint     `json:"billing_address_id,omitempty" db:"billing_address_id"`
 	Amount               float64   `json:"amount" db:"amount"`
-	Status               string    `json:"status" db:"status"` // pending, completed, failed, cancelled
+	Status               string    `json:"status" db:"status"` // pending, completed, failed, cancelled, needs_refund, partially_refunded, refunded
 	PaymentMethod        string    `json:"payment_method" db:"payment_method"`
 	GatewayTransactionID string    `json:"gateway_transaction_id" db:"gateway_transaction_id"`
 	GatewayResponse      string    `json:"gateway_response" db:"gateway_response"`
 	CreatedAt            time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+	Order                *Order    `json:"order,omitempty"`
 }
 
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCompleted = "completed"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusCancelled = "cancelled"
+	// PaymentStatusNeedsRefund marks a charged payment whose order could not
+	// be created. It has to be refunded or resolved by hand.
+	PaymentStatusNeedsRefund = "needs_refund"
+	// PaymentStatusPartiallyRefunded and PaymentStatusRefunded are derived
+	// from the succeeded refunds of a payment.
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+)
+
+// PaymentRequest checks out the cart. The addresses are resolved as for
+// OrderRequest and copied into the order once the payment completes.
 type PaymentRequest struct {
-	PaymentMethod string `json:"payment_method" binding:"required"` // credit_card, paypal, bank_transfer, etc.
+	PaymentMethod     string `json:"payment_method" binding:"required"` // credit_card, paypal, bank_transfer, etc.
+	ShippingAddressID *uint  `json:"shipping_address_id"`
+	BillingAddressID  *uint  `json:"billing_address_id"`
 }
 
 type PaymentGatewayResponse struct {
@@ -24,6 +47,30 @@ type PaymentGatewayResponse struct {
 	Message       string `json:"message"`
 }
 
+// Charge is the provider side view of a payment.
+type Charge struct {
+	TransactionID string  `json:"transaction_id"`
+	PaymentID     uint    `json:"payment_id"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"` // pending, succeeded, declined
+	Message       string  `json:"message"`
+}
+
+const (
+	ChargeStatusPending   = "pending"
+	ChargeStatusSucceeded = "succeeded"
+	ChargeStatusDeclined  = "declined"
+)
+
+// PaymentCallback is the body a provider posts to /payments/callback once a
+// charge has settled.
+type PaymentCallback struct {
+	PaymentID     uint   `json:"payment_id"`
+	TransactionID string `json:"transaction_id"`
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+}
+
 // Cart response models
 type CartItemResponse struct {
 	ProductID   uint    `json:"product_id"`
@@ -8,6 +8,8 @@ type Product struct {
     Description string    `json:"description" db:"description"`
     Price       float64   `json:"price" db:"price"`
     Stock       int       `json:"stock" db:"stock"`
+    // Available is Stock minus the units held for pending payments.
+    Available   int       `json:"available" db:"-"`
     Category    string    `json:"category" db:"category"`
     CreatedAt   time.Time `json:"created_at" db:"created_at"`
     UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
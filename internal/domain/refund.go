@@ -0,0 +1,59 @@
+package domain
+
+import "time"
+
+// Refund gives back part or all of a settled payment. Refunds made against
+// an order also record which order items they cover in Items.
+type Refund struct {
+	ID              uint         `json:"id" db:"id"`
+	PaymentID       uint         `json:"payment_id" db:"payment_id"`
+	OrderID         *uint        `json:"order_id,omitempty" db:"order_id"`
+	Amount          float64      `json:"amount" db:"amount"`
+	Status          string       `json:"status" db:"status"` // pending, succeeded, failed
+	Reason          string       `json:"reason" db:"reason"`
+	Restock         bool         `json:"restock" db:"restock"`
+	GatewayRefundID string       `json:"gateway_refund_id" db:"gateway_refund_id"`
+	GatewayResponse string       `json:"gateway_response" db:"gateway_response"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at" db:"updated_at"`
+	Items           []RefundItem `json:"items,omitempty"`
+}
+
+type RefundItem struct {
+	ID          uint    `json:"id" db:"id"`
+	RefundID    uint    `json:"refund_id" db:"refund_id"`
+	OrderItemID uint    `json:"order_item_id" db:"order_item_id"`
+	ProductID   uint    `json:"product_id" db:"product_id"`
+	Quantity    int     `json:"quantity" db:"quantity"`
+	Amount      float64 `json:"amount" db:"amount"`
+}
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// RefundRequest asks for a refund of a payment. OrderID defaults to the order
+// the payment paid for. Without an order the whole remaining amount is
+// refunded. With one the listed items of that order are refunded, or every
+// item not refunded yet when Items is empty.
+type RefundRequest struct {
+	OrderID *uint               `json:"order_id"`
+	Items   []RefundItemRequest `json:"items" binding:"dive"`
+	Restock bool                `json:"restock"`
+	Reason  string              `json:"reason" binding:"max=255"`
+}
+
+type RefundItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// GatewayRefund is the provider side view of a refund.
+type GatewayRefund struct {
+	RefundID      string  `json:"refund_id"`
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	Message       string  `json:"message"`
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// StockReservation holds units of a product for a pending payment until
+// ExpiresAt.
+type StockReservation struct {
+	ID        uint      `json:"id" db:"id"`
+	PaymentID uint      `json:"payment_id" db:"payment_id"`
+	ProductID uint      `json:"product_id" db:"product_id"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,86 @@
+package domain
+
+import "time"
+
+// Return is a customer's request to send back units of a shipped order. It
+// is approved or rejected, received and inspected, and once accepted the
+// units are restocked and refunded.
+type Return struct {
+	ID        uint         `json:"id" db:"id"`
+	OrderID   uint         `json:"order_id" db:"order_id"`
+	UserID    uint         `json:"user_id" db:"user_id"`
+	Status    string       `json:"status" db:"status"`
+	Reason    string       `json:"reason" db:"reason"`
+	Note      string       `json:"note" db:"note"` // left by the admin handling the return
+	Restocked bool         `json:"restocked" db:"restocked"`
+	RefundID  *uint        `json:"refund_id,omitempty" db:"refund_id"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+	Items     []ReturnItem `json:"items,omitempty"`
+}
+
+type ReturnItem struct {
+	ID          uint `json:"id" db:"id"`
+	ReturnID    uint `json:"return_id" db:"return_id"`
+	OrderItemID uint `json:"order_item_id" db:"order_item_id"`
+	ProductID   uint `json:"product_id" db:"product_id"`
+	Quantity    int  `json:"quantity" db:"quantity"`
+}
+
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received"
+	ReturnStatusAccepted  = "accepted"
+	ReturnStatusRefunded  = "refunded"
+	ReturnStatusCancelled = "cancelled"
+)
+
+// returnTransitions lists the statuses each return status can move to.
+// Rejected, refunded and cancelled returns are final.
+var returnTransitions = map[string][]string{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected, ReturnStatusCancelled},
+	ReturnStatusApproved:  {ReturnStatusReceived, ReturnStatusCancelled},
+	ReturnStatusReceived:  {ReturnStatusAccepted, ReturnStatusRejected},
+	ReturnStatusAccepted:  {ReturnStatusRefunded},
+}
+
+// CanTransitionReturn reports whether a return can move from status from to
+// status to.
+func CanTransitionReturn(from, to string) bool {
+	for _, s := range returnTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnFilter selects returns. Zero fields do not filter.
+type ReturnFilter struct {
+	Status  string
+	UserID  uint
+	OrderID uint
+	Limit   int
+	Offset  int
+}
+
+type CreateReturnRequest struct {
+	Items  []ReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+	Reason string              `json:"reason" binding:"required,max=255"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// UpdateReturnStatusRequest moves a return along on behalf of an admin.
+// Restock applies when the return is accepted and defaults to true; damaged
+// goods are refunded without going back to stock.
+type UpdateReturnStatusRequest struct {
+	Status  string `json:"status" binding:"required,oneof=approved rejected received accepted refunded"`
+	Note    string `json:"note" binding:"max=255"`
+	Restock *bool  `json:"restock"`
+}
@@ -0,0 +1,75 @@
+package domain
+
+import "time"
+
+// Shipment is a parcel that fulfils some or all units of an order's items.
+// An order can be split across several shipments.
+type Shipment struct {
+	ID             uint           `json:"id" db:"id"`
+	OrderID        uint           `json:"order_id" db:"order_id"`
+	Carrier        string         `json:"carrier" db:"carrier"`
+	TrackingNumber string         `json:"tracking_number" db:"tracking_number"`
+	Status         string         `json:"status" db:"status"` // pending, shipped, delivered, cancelled
+	ShippedAt      *time.Time     `json:"shipped_at,omitempty" db:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+	Items          []ShipmentItem `json:"items,omitempty"`
+}
+
+type ShipmentItem struct {
+	ID          uint `json:"id" db:"id"`
+	ShipmentID  uint `json:"shipment_id" db:"shipment_id"`
+	OrderItemID uint `json:"order_item_id" db:"order_item_id"`
+	ProductID   uint `json:"product_id" db:"product_id"`
+	Quantity    int  `json:"quantity" db:"quantity"`
+}
+
+const (
+	ShipmentStatusPending   = "pending"
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+	ShipmentStatusCancelled = "cancelled"
+)
+
+// shipmentTransitions lists the statuses each shipment status can move to.
+var shipmentTransitions = map[string][]string{
+	ShipmentStatusPending: {ShipmentStatusShipped, ShipmentStatusDelivered, ShipmentStatusCancelled},
+	ShipmentStatusShipped: {ShipmentStatusDelivered},
+}
+
+// CanTransitionShipment reports whether a shipment can move from status from
+// to status to.
+func CanTransitionShipment(from, to string) bool {
+	for _, s := range shipmentTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateShipmentRequest ships the listed items of an order, or every unit not
+// shipped or refunded yet when Items is empty. Status is pending by default,
+// or shipped for a parcel that has already left.
+type CreateShipmentRequest struct {
+	Carrier        string                `json:"carrier" binding:"required,max=50"`
+	TrackingNumber string                `json:"tracking_number" binding:"max=100"`
+	Status         string                `json:"status" binding:"omitempty,oneof=pending shipped"`
+	Items          []ShipmentItemRequest `json:"items" binding:"dive"`
+}
+
+type ShipmentItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// UpdateShipmentRequest changes the tracking details of a shipment or moves
+// it along. ShippedAt and DeliveredAt default to the time of the change.
+type UpdateShipmentRequest struct {
+	Carrier        *string    `json:"carrier" binding:"omitempty,min=1,max=50"`
+	TrackingNumber *string    `json:"tracking_number" binding:"omitempty,max=100"`
+	Status         string     `json:"status" binding:"omitempty,oneof=shipped delivered cancelled"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
@@ -4,16 +4,29 @@ import (
 	"time"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uint      `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"-" db:"password"`
-	Role      string    `json:"role" db:"role"`
-	TotalCart float64   `json:"total_cart" db:"total_cart"`
-	LockCart  bool      `json:"lock_cart" db:"lock_cart"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID            uint       `json:"id" db:"id"`
+	Username      string     `json:"username" db:"username"`
+	Email         string     `json:"email" db:"email"`
+	Password      string     `json:"-" db:"password"`
+	Role          string     `json:"role" db:"role"`
+	TotalCart     float64    `json:"total_cart" db:"total_cart"`
+	LockCart      bool       `json:"lock_cart" db:"lock_cart"`
+	LockPaymentID *uint      `json:"lock_payment_id,omitempty" db:"lock_payment_id"`
+	LockExpiresAt *time.Time `json:"lock_expires_at,omitempty" db:"lock_expires_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CartLocked reports whether a checkout lock is held and has not expired.
+// A lock without an expiry predates lock owners and is treated as lapsed.
+func (u *User) CartLocked(now time.Time) bool {
+	return u.LockCart && u.LockExpiresAt != nil && now.Before(*u.LockExpiresAt)
 }
 
 type UserRequest struct {
@@ -27,6 +40,16 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// Principal is the authenticated caller a usecase acts on behalf of.
+type Principal struct {
+	UserID uint
+	Role   string
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
@@ -0,0 +1,18 @@
+package gateway
+
+import (
+	"context"
+	"shop/internal/domain"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of a callback body.
+const SignatureHeader = "X-Gateway-Signature"
+
+type PaymentGateway interface {
+	CreateCharge(ctx context.Context, payment *domain.Payment) (*domain.Charge, error)
+	GetCharge(ctx context.Context, transactionID string) (*domain.Charge, error)
+	VerifyCallback(ctx context.Context, payload []byte, signature string) (*domain.PaymentCallback, error)
+	// Refund gives amount of a succeeded charge back to the customer. It may
+	// be called several times as long as the total stays within the charge.
+	Refund(ctx context.Context, transactionID string, amount float64) (*domain.GatewayRefund, error)
+}
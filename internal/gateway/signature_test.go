@@ -0,0 +1,37 @@
+package gateway_test
+
+import (
+	"strings"
+	"testing"
+
+	"shop/internal/gateway"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("callback-secret")
+	payload := []byte(`{"payment_id":1,"transaction_id":"sim_1","success":true}`)
+	signature := gateway.Sign(secret, payload)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{"signed", secret, payload, signature, true},
+		{"tampered payload", secret, []byte(`{"payment_id":1,"transaction_id":"sim_1","success":false}`), signature, false},
+		{"other secret", []byte("other-secret"), payload, signature, false},
+		{"unsigned", secret, payload, "", false},
+		{"not hex", secret, payload, "not-a-signature", false},
+		{"truncated", secret, payload, signature[:len(signature)-2], false},
+		{"uppercase hex", secret, payload, strings.ToUpper(signature), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gateway.VerifySignature(tt.secret, tt.payload, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
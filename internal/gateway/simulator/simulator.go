@@ -0,0 +1,217 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"shop/internal/domain"
+	"shop/internal/gateway"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome decides how every charge created by the simulator settles.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeDecline Outcome = "decline"
+	// OutcomeTimeout leaves charges pending and never calls back.
+	OutcomeTimeout Outcome = "timeout"
+)
+
+type Config struct {
+	Outcome Outcome
+	// Secret signs callbacks; the same value must be used to verify them.
+	Secret string
+	// CallbackURL receives the signed callback. Leave empty to settle charges
+	// without notifying anyone.
+	CallbackURL string
+	// Delay between creating a charge and settling it.
+	Delay time.Duration
+}
+
+// Gateway is a local PaymentGateway that settles charges by itself and posts
+// the result back like a real provider would.
+type Gateway struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.Mutex
+	charges map[string]*domain.Charge
+	// refunded sums the refunds made against each charge.
+	refunded map[string]float64
+}
+
+var _ gateway.PaymentGateway = (*Gateway)(nil)
+
+func New(cfg Config) *Gateway {
+	if cfg.Outcome == "" {
+		cfg.Outcome = OutcomeSuccess
+	}
+
+	return &Gateway{
+		cfg:      cfg,
+		client:   &http.Client{},
+		charges:  make(map[string]*domain.Charge),
+		refunded: make(map[string]float64),
+	}
+}
+
+func (g *Gateway) CreateCharge(ctx context.Context, payment *domain.Payment) (*domain.Charge, error) {
+	id, err := newTransactionID()
+	if err != nil {
+		return nil, err
+	}
+
+	charge := &domain.Charge{
+		TransactionID: id,
+		PaymentID:     payment.ID,
+		Amount:        payment.Amount,
+		Status:        domain.ChargeStatusPending,
+	}
+
+	// Copy the charge before settle can change it.
+	result := *charge
+	g.mu.Lock()
+	g.charges[id] = charge
+	g.mu.Unlock()
+
+	if g.cfg.Outcome != OutcomeTimeout {
+		go g.settle(id)
+	}
+
+	return &result, nil
+}
+
+func (g *Gateway) GetCharge(ctx context.Context, transactionID string) (*domain.Charge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[transactionID]
+	if !ok {
+		return nil, errors.New("charge not found")
+	}
+
+	result := *charge
+	return &result, nil
+}
+
+func (g *Gateway) VerifyCallback(ctx context.Context, payload []byte, signature string) (*domain.PaymentCallback, error) {
+	if !gateway.VerifySignature([]byte(g.cfg.Secret), payload, signature) {
+		return nil, errors.New("invalid callback signature")
+	}
+
+	var callback domain.PaymentCallback
+	if err := json.Unmarshal(payload, &callback); err != nil {
+		return nil, fmt.Errorf("invalid callback payload: %w", err)
+	}
+
+	return &callback, nil
+}
+
+// Refund settles immediately. It fails for charges that did not succeed and
+// for amounts above what is left of the charge.
+func (g *Gateway) Refund(ctx context.Context, transactionID string, amount float64) (*domain.GatewayRefund, error) {
+	id, err := newTransactionID()
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[transactionID]
+	if !ok {
+		return nil, errors.New("charge not found")
+	}
+	if charge.Status != domain.ChargeStatusSucceeded {
+		return nil, fmt.Errorf("charge is %s, only succeeded charges can be refunded", charge.Status)
+	}
+	// Amounts are in cents at the provider; compare them that way.
+	if math.Round((g.refunded[transactionID]+amount)*100) > math.Round(charge.Amount*100) {
+		return nil, fmt.Errorf("refund of %.2f exceeds the %.2f left on the charge", amount, charge.Amount-g.refunded[transactionID])
+	}
+	g.refunded[transactionID] += amount
+
+	return &domain.GatewayRefund{
+		RefundID:      "re" + strings.TrimPrefix(id, "sim"),
+		TransactionID: transactionID,
+		Amount:        amount,
+		Message:       "refunded by simulator",
+	}, nil
+}
+
+func (g *Gateway) settle(transactionID string) {
+	time.Sleep(g.cfg.Delay)
+
+	g.mu.Lock()
+	charge := g.charges[transactionID]
+	if g.cfg.Outcome == OutcomeSuccess {
+		charge.Status = domain.ChargeStatusSucceeded
+		charge.Message = "approved by simulator"
+	} else {
+		charge.Status = domain.ChargeStatusDeclined
+		charge.Message = "declined by simulator"
+	}
+	callback := domain.PaymentCallback{
+		PaymentID:     charge.PaymentID,
+		TransactionID: charge.TransactionID,
+		Success:       charge.Status == domain.ChargeStatusSucceeded,
+		Message:       charge.Message,
+	}
+	g.mu.Unlock()
+
+	if g.cfg.CallbackURL == "" {
+		return
+	}
+
+	// The callback outlives the request that created the charge.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := g.sendCallback(ctx, callback); err != nil {
+		log.Printf("simulator: callback for %s failed: %v", transactionID, err)
+	}
+}
+
+func (g *Gateway) sendCallback(ctx context.Context, callback domain.PaymentCallback) error {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.cfg.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(gateway.SignatureHeader, gateway.Sign([]byte(g.cfg.Secret), body))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newTransactionID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "sim_" + hex.EncodeToString(b), nil
+}
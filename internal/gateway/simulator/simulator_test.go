@@ -0,0 +1,47 @@
+package simulator_test
+
+import (
+	"context"
+	"testing"
+
+	"shop/internal/gateway"
+	"shop/internal/gateway/simulator"
+)
+
+func TestVerifyCallback(t *testing.T) {
+	secret := []byte("callback-secret")
+	payload := []byte(`{"payment_id":7,"transaction_id":"sim_1","success":true,"message":"approved by simulator"}`)
+	malformed := []byte(`{"payment_id":"seven"}`)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"signed", payload, gateway.Sign(secret, payload), false},
+		{"unsigned", payload, "", true},
+		{"tampered", []byte(`{"payment_id":8,"transaction_id":"sim_1","success":true}`), gateway.Sign(secret, payload), true},
+		{"signed by someone else", payload, gateway.Sign([]byte("other-secret"), payload), true},
+		{"signed but malformed", malformed, gateway.Sign(secret, malformed), true},
+	}
+
+	gw := simulator.New(simulator.Config{Secret: string(secret)})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := gw.VerifyCallback(context.Background(), tt.payload, tt.signature)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("VerifyCallback = %+v, want an error", callback)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyCallback: %v", err)
+			}
+			if callback.PaymentID != 7 || callback.TransactionID != "sim_1" || !callback.Success {
+				t.Errorf("callback = %+v, want the signed payload", callback)
+			}
+		})
+	}
+}
@@ -1,43 +1,185 @@
 package repository
 
-import "shop/internal/domain"
+import (
+	"context"
+	"shop/internal/domain"
+	"time"
+)
 
 type UserRepository interface {
-	Create(user *domain.User) error
-	GetByID(id uint) (*domain.User, error)
-	GetByEmail(email string) (*domain.User, error)
-	Update(user *domain.User) error
-	Delete(id uint) error
+	Create(ctx context.Context, user *domain.User) error
+	GetByID(ctx context.Context, id uint) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	// Update writes the profile fields and the cart total. The checkout lock
+	// only changes through LockCart and UnlockCart.
+	Update(ctx context.Context, user *domain.User) error
+	Delete(ctx context.Context, id uint) error
+	// LockCart takes the checkout lock for paymentID until expiresAt. It fails
+	// with domain.ErrCartLocked while another unexpired lock is held.
+	LockCart(ctx context.Context, userID, paymentID uint, expiresAt time.Time) error
+	// UnlockCart releases the lock held by paymentID. It fails with
+	// domain.ErrConflict when the lock is not held by that payment.
+	UnlockCart(ctx context.Context, userID, paymentID uint) error
 }
 
 type ProductRepository interface {
-	Create(product *domain.Product) error
-	GetByID(id uint) (*domain.Product, error)
-	GetAll(limit, offset int) ([]*domain.Product, error)
-	Update(product *domain.Product) error
-	Delete(id uint) error
-	UpdateStock(id uint, stock int) error
+	Create(ctx context.Context, product *domain.Product) error
+	GetByID(ctx context.Context, id uint) (*domain.Product, error)
+	GetAll(ctx context.Context, limit, offset int) ([]*domain.Product, error)
+	Update(ctx context.Context, product *domain.Product) error
+	Delete(ctx context.Context, id uint) error
+	UpdateStock(ctx context.Context, id uint, stock int) error
+	// DecrementStock subtracts quantity from the stock of product id only if
+	// enough is left that is not held by unexpired reservations, and returns
+	// the number of rows changed: 0 when the product is missing or short.
+	DecrementStock(ctx context.Context, id uint, quantity int) (int64, error)
+	// IncrementStock adds quantity back to the stock of product id and returns
+	// the number of rows changed.
+	IncrementStock(ctx context.Context, id uint, quantity int) (int64, error)
 }
 
 type OrderRepository interface {
-	Create(order *domain.Order) error
-	GetByID(id uint) (*domain.Order, error)
-	GetByUserID(userID uint, limit, offset int) ([]*domain.Order, error)
-	Update(order *domain.Order) error
-	CreateOrderItem(item *domain.OrderItem) error
-	GetOrderItems(orderID uint) ([]*domain.OrderItem, error)
+	Create(ctx context.Context, order *domain.Order) error
+	GetByID(ctx context.Context, id uint) (*domain.Order, error)
+	GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Order, error)
+	// List returns the page of orders selected by filter and the number of
+	// orders that match it across all pages.
+	List(ctx context.Context, filter domain.OrderFilter) ([]*domain.Order, int, error)
+	// Update writes the total. The status only changes through
+	// TransitionStatus.
+	Update(ctx context.Context, order *domain.Order) error
+	CreateOrderItem(ctx context.Context, item *domain.OrderItem) error
+	GetOrderItems(ctx context.Context, orderID uint) ([]*domain.OrderItem, error)
+	// TransitionStatus moves order id from status from to status to and
+	// returns the number of rows changed: 0 when it is no longer in from.
+	TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error)
+	AddStatusHistory(ctx context.Context, entry *domain.OrderStatusHistory) error
+	// GetStatusHistory lists the status changes of an order, oldest first.
+	GetStatusHistory(ctx context.Context, orderID uint) ([]domain.OrderStatusHistory, error)
+	// AddAddress stores a copy of an address for an order.
+	AddAddress(ctx context.Context, address *domain.OrderAddress) error
+	// GetAddresses lists the address copies of an order.
+	GetAddresses(ctx context.Context, orderID uint) ([]domain.OrderAddress, error)
 }
 
 type CartItemsRepository interface {
-	GetByUserID(userID uint) ([]domain.CartItems, error)
-	Update(cartitem *domain.CartItems) error
-	CreateCartItems(userid uint, item domain.CartItems) error
-	ClearCart(userID uint) error //
+	GetByUserID(ctx context.Context, userID uint) ([]domain.CartItems, error)
+	Update(ctx context.Context, cartitem *domain.CartItems) error
+	CreateCartItems(ctx context.Context, userid uint, item domain.CartItems) error
+	// UpsertCartItem inserts item, or when the product is already in the cart
+	// adds item.Quantity to it, or sets it when replace is true.
+	UpsertCartItem(ctx context.Context, userID uint, item domain.CartItems, replace bool) error
+	GetCartItemByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.CartItems, error)
+	DeleteCartItem(ctx context.Context, userID, productID uint) error
+	ClearCart(ctx context.Context, userID uint) error
 }
 
 type PaymentRepository interface {
-	Create(payment *domain.Payment) error
-	GetByID(id uint) (*domain.Payment, error)
-	GetByUserID(userID uint, limit, offset int) ([]*domain.Payment, error)
-	Update(payment *domain.Payment) error
+	Create(ctx context.Context, payment *domain.Payment) error
+	GetByID(ctx context.Context, id uint) (*domain.Payment, error)
+	GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Payment, error)
+	// GetByOrderID lists the payments linked to an order, oldest first.
+	GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Payment, error)
+	Update(ctx context.Context, payment *domain.Payment) error
+	// GetPendingBefore lists up to limit payments that are still pending and
+	// were created before cutoff, oldest first.
+	GetPendingBefore(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Payment, error)
+	// TransitionStatus moves payment id from status from to status to and
+	// returns the number of rows changed: 0 when it is no longer in from.
+	TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error)
+	// SetGatewayTransactionID records the charge created for payment id and
+	// leaves its status alone, which a callback may already have settled.
+	SetGatewayTransactionID(ctx context.Context, id uint, transactionID string) error
+}
+
+type ReservationRepository interface {
+	// Reserve inserts r only if the product still has r.Quantity units that
+	// are not held by unexpired reservations, and returns the number of rows
+	// inserted: 0 when the product is missing or short.
+	Reserve(ctx context.Context, r *domain.StockReservation) (int64, error)
+	// HeldQuantities sums the unexpired reservations of each product.
+	HeldQuantities(ctx context.Context, productIDs []uint) (map[uint]int, error)
+	DeleteByPaymentID(ctx context.Context, paymentID uint) error
+}
+
+type RefundRepository interface {
+	// Create inserts refund and its items. Call it inside a unit of work so
+	// that a refund is never stored without its items.
+	Create(ctx context.Context, refund *domain.Refund) error
+	GetByID(ctx context.Context, id uint) (*domain.Refund, error)
+	GetByPaymentID(ctx context.Context, paymentID uint) ([]*domain.Refund, error)
+	// Update writes the status and the gateway fields of refund.
+	Update(ctx context.Context, refund *domain.Refund) error
+	// TotalByPaymentID sums the amounts of the payment's refunds that are in
+	// one of statuses.
+	TotalByPaymentID(ctx context.Context, paymentID uint, statuses ...string) (float64, error)
+	// RefundedQuantities sums, per order item, the quantities of the order's
+	// refunds that are in one of statuses.
+	RefundedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error)
+}
+
+type AddressRepository interface {
+	Create(ctx context.Context, address *domain.Address) error
+	GetByID(ctx context.Context, id uint) (*domain.Address, error)
+	// GetByUserID lists a customer's address book, newest first.
+	GetByUserID(ctx context.Context, userID uint) ([]*domain.Address, error)
+	Update(ctx context.Context, address *domain.Address) error
+	Delete(ctx context.Context, id uint) error
+	// ClearDefault unsets the customer's default address of kind.
+	ClearDefault(ctx context.Context, userID uint, kind string) error
+}
+
+type ShipmentRepository interface {
+	// Create inserts shipment and its items. Call it inside a unit of work so
+	// that a shipment is never stored without its items.
+	Create(ctx context.Context, shipment *domain.Shipment) error
+	GetByID(ctx context.Context, id uint) (*domain.Shipment, error)
+	// GetByOrderID lists the shipments of an order with their items, oldest
+	// first.
+	GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Shipment, error)
+	// Update writes the carrier, tracking number, status and timestamps of
+	// shipment.
+	Update(ctx context.Context, shipment *domain.Shipment) error
+	// ShippedQuantities sums, per order item, the quantities of the order's
+	// shipments that are in one of statuses.
+	ShippedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error)
+}
+
+type ReturnRepository interface {
+	// Create inserts ret and its items. Call it inside a unit of work so that
+	// a return is never stored without its items.
+	Create(ctx context.Context, ret *domain.Return) error
+	GetByID(ctx context.Context, id uint) (*domain.Return, error)
+	// List returns the returns selected by filter with their items, newest
+	// first.
+	List(ctx context.Context, filter domain.ReturnFilter) ([]*domain.Return, error)
+	// Update writes the note, the restocked flag and the refund of ret. The
+	// status only changes through TransitionStatus.
+	Update(ctx context.Context, ret *domain.Return) error
+	// TransitionStatus moves return id from status from to status to and
+	// returns the number of rows changed: 0 when it is no longer in from.
+	TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error)
+	// ReturnedQuantities sums, per order item, the quantities of the order's
+	// returns that are in one of statuses.
+	ReturnedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error)
+}
+
+// Repositories groups the repositories that can take part in a unit of work.
+type Repositories struct {
+	Users        UserRepository
+	Products     ProductRepository
+	Orders       OrderRepository
+	Carts        CartItemsRepository
+	Payments     PaymentRepository
+	Reservations ReservationRepository
+	Refunds      RefundRepository
+	Shipments    ShipmentRepository
+	Returns      ReturnRepository
+	Addresses    AddressRepository
+}
+
+// UnitOfWork runs fn with repositories bound to a single transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(repos Repositories) error) error
 }
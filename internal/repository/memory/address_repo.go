@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type addressRepository struct {
+	base
+}
+
+func NewAddressRepository(store *Store) *addressRepository {
+	return &addressRepository{base{store: store}}
+}
+
+func (r *addressRepository) Create(ctx context.Context, address *domain.Address) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.users[address.UserID]; !ok {
+		return foreignKey("addresses", "user_id")
+	}
+
+	now := time.Now()
+	address.ID = t.newID("addresses")
+	address.CreatedAt = now
+	address.UpdatedAt = now
+	t.addresses[address.ID] = *address
+	return nil
+}
+
+func (r *addressRepository) GetByID(ctx context.Context, id uint) (*domain.Address, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	address, ok := t.addresses[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &address, nil
+}
+
+func (r *addressRepository) GetByUserID(ctx context.Context, userID uint) ([]*domain.Address, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var addresses []*domain.Address
+	for _, address := range t.addresses {
+		if address.UserID == userID {
+			address := address
+			addresses = append(addresses, &address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].ID > addresses[j].ID })
+	return addresses, nil
+}
+
+func (r *addressRepository) Update(ctx context.Context, address *domain.Address) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.addresses[address.ID]
+	if !ok {
+		return errNotFound
+	}
+	address.UpdatedAt = time.Now()
+	row.Label = address.Label
+	row.PostalAddress = address.PostalAddress
+	row.DefaultShipping = address.DefaultShipping
+	row.DefaultBilling = address.DefaultBilling
+	row.UpdatedAt = address.UpdatedAt
+	t.addresses[address.ID] = row
+	return nil
+}
+
+func (r *addressRepository) Delete(ctx context.Context, id uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Mirror ON DELETE SET NULL of the payments and order_addresses tables.
+	for pid, payment := range t.payments {
+		if payment.ShippingAddressID != nil && *payment.ShippingAddressID == id {
+			payment.ShippingAddressID = nil
+		}
+		if payment.BillingAddressID != nil && *payment.BillingAddressID == id {
+			payment.BillingAddressID = nil
+		}
+		t.payments[pid] = payment
+	}
+	for oid, address := range t.orderAddresses {
+		if address.AddressID != nil && *address.AddressID == id {
+			address.AddressID = nil
+			t.orderAddresses[oid] = address
+		}
+	}
+	delete(t.addresses, id)
+	return nil
+}
+
+func (r *addressRepository) ClearDefault(ctx context.Context, userID uint, kind string) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	for id, address := range t.addresses {
+		if address.UserID != userID {
+			continue
+		}
+		switch {
+		case kind == domain.AddressKindShipping && address.DefaultShipping:
+			address.DefaultShipping = false
+		case kind == domain.AddressKindBilling && address.DefaultBilling:
+			address.DefaultBilling = false
+		default:
+			continue
+		}
+		address.UpdatedAt = now
+		t.addresses[id] = address
+	}
+	return nil
+}
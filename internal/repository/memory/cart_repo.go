@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type cartItemRepository struct {
+	base
+}
+
+func NewCartRepository(store *Store) *cartItemRepository {
+	return &cartItemRepository{base{store: store}}
+}
+
+func (r *cartItemRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.CartItems, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var rows []cartRow
+	for key, row := range t.cartItems {
+		if key.userID == userID {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+
+	var cartItems []domain.CartItems
+	for _, row := range rows {
+		cartItems = append(cartItems, row.item)
+	}
+	return cartItems, nil
+}
+
+func (r *cartItemRepository) CreateCartItems(ctx context.Context, userID uint, item domain.CartItems) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key := cartKey{userID: userID, productID: item.ProductId}
+	if _, ok := t.cartItems[key]; ok {
+		return duplicateEntry(key.userID, "unique_user_product")
+	}
+	if _, ok := t.products[item.ProductId]; !ok {
+		return foreignKey("cart_items", "product_id")
+	}
+
+	now := time.Now()
+	item.UserId = userID
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	t.cartItems[key] = cartRow{id: t.newID("cart_items"), item: item}
+	return nil
+}
+
+func (r *cartItemRepository) UpsertCartItem(ctx context.Context, userID uint, item domain.CartItems, replace bool) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.products[item.ProductId]; !ok {
+		return foreignKey("cart_items", "product_id")
+	}
+
+	now := time.Now()
+	key := cartKey{userID: userID, productID: item.ProductId}
+	row, ok := t.cartItems[key]
+	if !ok {
+		item.UserId = userID
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		t.cartItems[key] = cartRow{id: t.newID("cart_items"), item: item}
+		return nil
+	}
+
+	if replace {
+		row.item.Quantity = item.Quantity
+	} else {
+		row.item.Quantity += item.Quantity
+	}
+	row.item.Fee = item.Fee
+	row.item.UpdatedAt = now
+	t.cartItems[key] = row
+	return nil
+}
+
+func (r *cartItemRepository) Update(ctx context.Context, cartItem *domain.CartItems) error {
+	return r.UpdateCartItem(ctx, cartItem.UserId, *cartItem)
+}
+
+func (r *cartItemRepository) UpdateCartItem(ctx context.Context, userID uint, item domain.CartItems) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key := cartKey{userID: userID, productID: item.ProductId}
+	row, ok := t.cartItems[key]
+	if !ok {
+		return errNotFound
+	}
+
+	row.item.Quantity = item.Quantity
+	row.item.Fee = item.Fee
+	row.item.UpdatedAt = time.Now()
+	t.cartItems[key] = row
+	return nil
+}
+
+func (r *cartItemRepository) DeleteCartItem(ctx context.Context, userID, productID uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	delete(t.cartItems, cartKey{userID: userID, productID: productID})
+	return nil
+}
+
+func (r *cartItemRepository) ClearCart(ctx context.Context, userID uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for key := range t.cartItems {
+		if key.userID == userID {
+			delete(t.cartItems, key)
+		}
+	}
+	return nil
+}
+
+func (r *cartItemRepository) GetCartItemByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.CartItems, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	row, ok := t.cartItems[cartKey{userID: userID, productID: productID}]
+	if !ok {
+		return nil, errNotFound
+	}
+	item := row.item
+	return &item, nil
+}
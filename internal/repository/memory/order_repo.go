@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type orderRepository struct {
+	base
+}
+
+func NewOrderRepository(store *Store) *orderRepository {
+	return &orderRepository{base{store: store}}
+}
+
+func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.users[order.UserID]; !ok {
+		return foreignKey("orders", "user_id")
+	}
+
+	now := time.Now()
+	row := *order
+	row.ID = t.newID("orders")
+	row.Items = nil
+	row.Payments = nil
+	row.History = nil
+	row.CreatedAt = now
+	row.UpdatedAt = now
+	if row.Status == "" {
+		row.Status = domain.OrderStatusPending
+	}
+	t.orders[row.ID] = row
+
+	order.ID = row.ID
+	return nil
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id uint) (*domain.Order, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	order, ok := t.orders[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Order, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var ids []uint
+	for id, order := range t.orders {
+		if order.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var orders []*domain.Order
+	for _, id := range page(ids, limit, offset) {
+		order := t.orders[id]
+		orders = append(orders, &order)
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.orders[order.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	row.Total = order.Total
+	row.UpdatedAt = time.Now()
+	t.orders[row.ID] = row
+	return nil
+}
+
+func (r *orderRepository) CreateOrderItem(ctx context.Context, item *domain.OrderItem) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.orders[item.OrderID]; !ok {
+		return foreignKey("order_items", "order_id")
+	}
+	if _, ok := t.products[item.ProductID]; !ok {
+		return foreignKey("order_items", "product_id")
+	}
+
+	row := *item
+	row.ID = t.newID("order_items")
+	row.Product = domain.Product{}
+	t.orderItems[row.ID] = row
+
+	item.ID = row.ID
+	return nil
+}
+
+func (r *orderRepository) GetOrderItems(ctx context.Context, orderID uint) ([]*domain.OrderItem, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var items []*domain.OrderItem
+	for _, row := range t.orderItems {
+		if row.OrderID != orderID {
+			continue
+		}
+		// Same as the JOIN on products in MySQL
+		product, ok := t.products[row.ProductID]
+		if !ok {
+			continue
+		}
+		item := row
+		item.Product = product
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items, nil
+}
+
+func (r *orderRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	row, ok := t.orders[id]
+	if !ok || row.Status != from {
+		return 0, nil
+	}
+
+	row.Status = to
+	row.UpdatedAt = time.Now()
+	t.orders[id] = row
+	return 1, nil
+}
+
+func (r *orderRepository) AddStatusHistory(ctx context.Context, entry *domain.OrderStatusHistory) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.orders[entry.OrderID]; !ok {
+		return foreignKey("order_status_history", "order_id")
+	}
+
+	entry.ID = t.newID("order_status_history")
+	entry.CreatedAt = time.Now()
+	row := *entry
+	row.ChangedBy = copyID(entry.ChangedBy)
+	t.orderHistory[row.ID] = row
+	return nil
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID uint) ([]domain.OrderStatusHistory, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var history []domain.OrderStatusHistory
+	for _, entry := range t.orderHistory {
+		if entry.OrderID == orderID {
+			entry.ChangedBy = copyID(entry.ChangedBy)
+			history = append(history, entry)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].ID < history[j].ID })
+	return history, nil
+}
+
+func (r *orderRepository) AddAddress(ctx context.Context, address *domain.OrderAddress) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.orders[address.OrderID]; !ok {
+		return foreignKey("order_addresses", "order_id")
+	}
+	if address.AddressID != nil {
+		if _, ok := t.addresses[*address.AddressID]; !ok {
+			return foreignKey("order_addresses", "address_id")
+		}
+	}
+	for _, row := range t.orderAddresses {
+		if row.OrderID == address.OrderID && row.Kind == address.Kind {
+			return duplicateEntry(address.Kind, "unique_order_address_kind")
+		}
+	}
+
+	address.ID = t.newID("order_addresses")
+	address.CreatedAt = time.Now()
+	row := *address
+	row.AddressID = copyID(address.AddressID)
+	t.orderAddresses[row.ID] = row
+	return nil
+}
+
+func (r *orderRepository) GetAddresses(ctx context.Context, orderID uint) ([]domain.OrderAddress, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var addresses []domain.OrderAddress
+	for _, address := range t.orderAddresses {
+		if address.OrderID == orderID {
+			address.AddressID = copyID(address.AddressID)
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].ID < addresses[j].ID })
+	return addresses, nil
+}
+
+func (r *orderRepository) List(ctx context.Context, filter domain.OrderFilter) ([]*domain.Order, int, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	var matched []domain.Order
+	for _, order := range t.orders {
+		switch {
+		case filter.Status != "" && order.Status != filter.Status,
+			filter.UserID != 0 && order.UserID != filter.UserID,
+			filter.CreatedFrom != nil && order.CreatedAt.Before(*filter.CreatedFrom),
+			filter.CreatedTo != nil && !order.CreatedAt.Before(*filter.CreatedTo),
+			filter.MinTotal != nil && order.Total < *filter.MinTotal,
+			filter.MaxTotal != nil && order.Total > *filter.MaxTotal:
+			continue
+		}
+		matched = append(matched, order)
+	}
+
+	less := func(a, b domain.Order) bool {
+		switch filter.SortBy {
+		case domain.OrderSortID:
+		case domain.OrderSortTotal:
+			if a.Total != b.Total {
+				return a.Total < b.Total
+			}
+		default:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if filter.Descending {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	var orders []*domain.Order
+	for _, order := range page(matched, filter.Limit, filter.Offset) {
+		order := order
+		orders = append(orders, &order)
+	}
+	return orders, len(matched), nil
+}
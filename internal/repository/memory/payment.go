@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type paymentRepository struct {
+	base
+}
+
+func NewPaymentRepository(store *Store) *paymentRepository {
+	return &paymentRepository{base{store: store}}
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	row := *payment
+	row.OrderID = copyID(payment.OrderID)
+	row.ShippingAddressID = copyID(payment.ShippingAddressID)
+	row.BillingAddressID = copyID(payment.BillingAddressID)
+	row.ID = t.newID("payments")
+	row.CreatedAt = now
+	row.UpdatedAt = now
+	t.payments[row.ID] = row
+
+	payment.ID = row.ID
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+	return nil
+}
+
+func (r *paymentRepository) GetByID(ctx context.Context, id uint) (*domain.Payment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, ok := t.payments[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Payment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payments := r.userPayments(t, userID, "")
+
+	var result []*domain.Payment
+	for _, payment := range page(payments, limit, offset) {
+		payment := payment
+		result = append(result, &payment)
+	}
+	return result, nil
+}
+
+func (r *paymentRepository) GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Payment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var payments []*domain.Payment
+	for _, payment := range t.payments {
+		if payment.OrderID != nil && *payment.OrderID == orderID {
+			payment := payment
+			payments = append(payments, &payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].CreatedAt.Equal(payments[j].CreatedAt) {
+			return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+		}
+		return payments[i].ID < payments[j].ID
+	})
+	return payments, nil
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *domain.Payment) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.payments[payment.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	payment.UpdatedAt = time.Now()
+	row.OrderID = copyID(payment.OrderID)
+	row.ShippingAddressID = copyID(payment.ShippingAddressID)
+	row.BillingAddressID = copyID(payment.BillingAddressID)
+	row.Amount = payment.Amount
+	row.Status = payment.Status
+	row.PaymentMethod = payment.PaymentMethod
+	row.GatewayTransactionID = payment.GatewayTransactionID
+	row.GatewayResponse = payment.GatewayResponse
+	row.UpdatedAt = payment.UpdatedAt
+	t.payments[row.ID] = row
+	return nil
+}
+
+func (r *paymentRepository) GetPendingPaymentByUserID(ctx context.Context, userID uint) (*domain.Payment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payments := r.userPayments(t, userID, domain.PaymentStatusPending)
+	if len(payments) == 0 {
+		return nil, errNotFound
+	}
+	return &payments[0], nil
+}
+
+func (r *paymentRepository) GetPendingBefore(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Payment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var pending []domain.Payment
+	for _, payment := range t.payments {
+		if payment.Status == domain.PaymentStatusPending && payment.CreatedAt.Before(cutoff) {
+			pending = append(pending, payment)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+			return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+		}
+		return pending[i].ID < pending[j].ID
+	})
+
+	var result []*domain.Payment
+	for _, payment := range page(pending, limit, 0) {
+		payment := payment
+		result = append(result, &payment)
+	}
+	return result, nil
+}
+
+func (r *paymentRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	row, ok := t.payments[id]
+	if !ok || row.Status != from {
+		return 0, nil
+	}
+
+	row.Status = to
+	row.UpdatedAt = time.Now()
+	t.payments[id] = row
+	return 1, nil
+}
+
+func (r *paymentRepository) SetGatewayTransactionID(ctx context.Context, id uint, transactionID string) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.payments[id]
+	if !ok {
+		return errNotFound
+	}
+	row.GatewayTransactionID = transactionID
+	row.UpdatedAt = time.Now()
+	t.payments[id] = row
+	return nil
+}
+
+// userPayments returns the payments of a user, newest first, optionally
+// filtered by status.
+func (r *paymentRepository) userPayments(t *tables, userID uint, status string) []domain.Payment {
+	var payments []domain.Payment
+	for _, payment := range t.payments {
+		if payment.UserID == userID && (status == "" || payment.Status == status) {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].CreatedAt.Equal(payments[j].CreatedAt) {
+			return payments[i].CreatedAt.After(payments[j].CreatedAt)
+		}
+		return payments[i].ID > payments[j].ID
+	})
+	return payments
+}
+
+// copyID copies an optional ID so rows never share it with callers.
+func copyID(id *uint) *uint {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
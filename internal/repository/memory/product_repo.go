@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type productRepository struct {
+	base
+}
+
+func NewProductRepository(store *Store) *productRepository {
+	return &productRepository{base{store: store}}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *domain.Product) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	row := *product
+	row.ID = t.newID("products")
+	row.CreatedAt = now
+	row.UpdatedAt = now
+	t.products[row.ID] = row
+
+	product.ID = row.ID
+	return nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id uint) (*domain.Product, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	product, ok := t.products[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &product, nil
+}
+
+func (r *productRepository) GetAll(ctx context.Context, limit, offset int) ([]*domain.Product, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ids := make([]uint, 0, len(t.products))
+	for id := range t.products {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var products []*domain.Product
+	for _, id := range page(ids, limit, offset) {
+		product := t.products[id]
+		products = append(products, &product)
+	}
+	return products, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *domain.Product) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.products[product.ID]
+	if !ok {
+		return errNotFound
+	}
+
+	row.Name = product.Name
+	row.Description = product.Description
+	row.Price = product.Price
+	row.Stock = product.Stock
+	row.Category = product.Category
+	row.UpdatedAt = time.Now()
+	t.products[row.ID] = row
+	return nil
+}
+
+func (r *productRepository) Delete(ctx context.Context, id uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, item := range t.orderItems {
+		if item.ProductID == id {
+			return foreignKey("order_items", "product_id")
+		}
+	}
+	for key := range t.cartItems {
+		if key.productID == id {
+			return foreignKey("cart_items", "product_id")
+		}
+	}
+	for _, res := range t.reservations {
+		if res.ProductID == id {
+			return foreignKey("stock_reservations", "product_id")
+		}
+	}
+
+	delete(t.products, id)
+	return nil
+}
+
+func (r *productRepository) UpdateStock(ctx context.Context, id uint, stock int) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.products[id]
+	if !ok {
+		return errNotFound
+	}
+
+	row.Stock = stock
+	row.UpdatedAt = time.Now()
+	t.products[id] = row
+	return nil
+}
+
+func (r *productRepository) DecrementStock(ctx context.Context, id uint, quantity int) (int64, error) {
+	return r.addStock(ctx, id, -quantity)
+}
+
+func (r *productRepository) IncrementStock(ctx context.Context, id uint, quantity int) (int64, error) {
+	return r.addStock(ctx, id, quantity)
+}
+
+// addStock applies delta unless the product is missing or short, and returns
+// the number of rows changed.
+func (r *productRepository) addStock(ctx context.Context, id uint, delta int) (int64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	row, ok := t.products[id]
+	if !ok {
+		return 0, nil
+	}
+	// A decrement may not take units held by unexpired reservations.
+	if delta < 0 && row.Stock-t.held(id, time.Now())+delta < 0 {
+		return 0, nil
+	}
+
+	row.Stock += delta
+	row.UpdatedAt = time.Now()
+	t.products[id] = row
+	return 1, nil
+}
+
+// page applies LIMIT and OFFSET to an ordered slice.
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type refundRepository struct {
+	base
+}
+
+func NewRefundRepository(store *Store) *refundRepository {
+	return &refundRepository{base{store: store}}
+}
+
+func (r *refundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.payments[refund.PaymentID]; !ok {
+		return foreignKey("refunds", "payment_id")
+	}
+	if refund.OrderID != nil {
+		if _, ok := t.orders[*refund.OrderID]; !ok {
+			return foreignKey("refunds", "order_id")
+		}
+	}
+	seen := make(map[uint]bool, len(refund.Items))
+	for _, item := range refund.Items {
+		if _, ok := t.orderItems[item.OrderItemID]; !ok {
+			return foreignKey("refund_items", "order_item_id")
+		}
+		if seen[item.OrderItemID] {
+			return duplicateEntry(item.OrderItemID, "unique_refund_order_item")
+		}
+		seen[item.OrderItemID] = true
+	}
+
+	now := time.Now()
+	refund.ID = t.newID("refunds")
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+	for i := range refund.Items {
+		refund.Items[i].ID = t.newID("refund_items")
+		refund.Items[i].RefundID = refund.ID
+	}
+	t.refunds[refund.ID] = *copyRefund(refund)
+	return nil
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id uint) (*domain.Refund, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	refund, ok := t.refunds[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return copyRefund(&refund), nil
+}
+
+func (r *refundRepository) GetByPaymentID(ctx context.Context, paymentID uint) ([]*domain.Refund, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var refunds []*domain.Refund
+	for _, refund := range t.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, copyRefund(&refund))
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+func (r *refundRepository) Update(ctx context.Context, refund *domain.Refund) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.refunds[refund.ID]
+	if !ok {
+		return errNotFound
+	}
+	refund.UpdatedAt = time.Now()
+	row.Status = refund.Status
+	row.GatewayRefundID = refund.GatewayRefundID
+	row.GatewayResponse = refund.GatewayResponse
+	row.UpdatedAt = refund.UpdatedAt
+	t.refunds[refund.ID] = row
+	return nil
+}
+
+func (r *refundRepository) TotalByPaymentID(ctx context.Context, paymentID uint, statuses ...string) (float64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var total float64
+	for _, refund := range t.refunds {
+		if refund.PaymentID == paymentID && hasStatus(refund.Status, statuses) {
+			total += refund.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *refundRepository) RefundedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	refunded := make(map[uint]int)
+	for _, refund := range t.refunds {
+		if refund.OrderID == nil || *refund.OrderID != orderID || !hasStatus(refund.Status, statuses) {
+			continue
+		}
+		for _, item := range refund.Items {
+			refunded[item.OrderItemID] += item.Quantity
+		}
+	}
+	return refunded, nil
+}
+
+// copyRefund copies refund so callers never share its items with the store.
+func copyRefund(refund *domain.Refund) *domain.Refund {
+	c := *refund
+	c.Items = append([]domain.RefundItem(nil), refund.Items...)
+	c.OrderID = copyID(refund.OrderID)
+	return &c
+}
+
+func hasStatus(status string, statuses []string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"time"
+)
+
+type reservationRepository struct {
+	base
+}
+
+func NewReservationRepository(store *Store) *reservationRepository {
+	return &reservationRepository{base{store: store}}
+}
+
+func (r *reservationRepository) Reserve(ctx context.Context, res *domain.StockReservation) (int64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	product, ok := t.products[res.ProductID]
+	if !ok || product.Stock-t.held(res.ProductID, now) < res.Quantity {
+		return 0, nil
+	}
+	if _, ok := t.payments[res.PaymentID]; !ok {
+		return 0, foreignKey("stock_reservations", "payment_id")
+	}
+	for _, other := range t.reservations {
+		if other.PaymentID == res.PaymentID && other.ProductID == res.ProductID {
+			return 0, duplicateEntry(res.ProductID, "unique_payment_product")
+		}
+	}
+
+	res.ID = t.newID("stock_reservations")
+	res.CreatedAt = now
+	t.reservations[res.ID] = *res
+	return 1, nil
+}
+
+func (r *reservationRepository) HeldQuantities(ctx context.Context, productIDs []uint) (map[uint]int, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	held := make(map[uint]int)
+	for _, id := range productIDs {
+		if n := t.held(id, now); n > 0 {
+			held[id] = n
+		}
+	}
+	return held, nil
+}
+
+func (r *reservationRepository) DeleteByPaymentID(ctx context.Context, paymentID uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for id, res := range t.reservations {
+		if res.PaymentID == paymentID {
+			delete(t.reservations, id)
+		}
+	}
+	return nil
+}
+
+// held sums the reservations of a product that are unexpired at now.
+func (t *tables) held(productID uint, now time.Time) int {
+	var n int
+	for _, res := range t.reservations {
+		if res.ProductID == productID && res.ExpiresAt.After(now) {
+			n += res.Quantity
+		}
+	}
+	return n
+}
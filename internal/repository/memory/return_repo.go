@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type returnRepository struct {
+	base
+}
+
+func NewReturnRepository(store *Store) *returnRepository {
+	return &returnRepository{base{store: store}}
+}
+
+func (r *returnRepository) Create(ctx context.Context, ret *domain.Return) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.orders[ret.OrderID]; !ok {
+		return foreignKey("order_returns", "order_id")
+	}
+	if _, ok := t.users[ret.UserID]; !ok {
+		return foreignKey("order_returns", "user_id")
+	}
+	seen := make(map[uint]bool, len(ret.Items))
+	for _, item := range ret.Items {
+		if _, ok := t.orderItems[item.OrderItemID]; !ok {
+			return foreignKey("order_return_items", "order_item_id")
+		}
+		if seen[item.OrderItemID] {
+			return duplicateEntry(item.OrderItemID, "unique_return_order_item")
+		}
+		seen[item.OrderItemID] = true
+	}
+
+	now := time.Now()
+	ret.ID = t.newID("order_returns")
+	ret.CreatedAt = now
+	ret.UpdatedAt = now
+	for i := range ret.Items {
+		ret.Items[i].ID = t.newID("order_return_items")
+		ret.Items[i].ReturnID = ret.ID
+	}
+	t.returns[ret.ID] = *copyReturn(ret)
+	return nil
+}
+
+func (r *returnRepository) GetByID(ctx context.Context, id uint) (*domain.Return, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ret, ok := t.returns[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return copyReturn(&ret), nil
+}
+
+func (r *returnRepository) List(ctx context.Context, filter domain.ReturnFilter) ([]*domain.Return, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var returns []*domain.Return
+	for _, ret := range t.returns {
+		if (filter.Status != "" && ret.Status != filter.Status) ||
+			(filter.UserID != 0 && ret.UserID != filter.UserID) ||
+			(filter.OrderID != 0 && ret.OrderID != filter.OrderID) {
+			continue
+		}
+		returns = append(returns, copyReturn(&ret))
+	}
+	sort.Slice(returns, func(i, j int) bool { return returns[i].ID > returns[j].ID })
+
+	if filter.Limit > 0 {
+		if filter.Offset >= len(returns) {
+			return nil, nil
+		}
+		returns = returns[filter.Offset:]
+		if len(returns) > filter.Limit {
+			returns = returns[:filter.Limit]
+		}
+	}
+	return returns, nil
+}
+
+func (r *returnRepository) Update(ctx context.Context, ret *domain.Return) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.returns[ret.ID]
+	if !ok {
+		return errNotFound
+	}
+	if ret.RefundID != nil {
+		if _, ok := t.refunds[*ret.RefundID]; !ok {
+			return foreignKey("order_returns", "refund_id")
+		}
+	}
+	ret.UpdatedAt = time.Now()
+	row.Note = ret.Note
+	row.Restocked = ret.Restocked
+	row.RefundID = copyID(ret.RefundID)
+	row.UpdatedAt = ret.UpdatedAt
+	t.returns[ret.ID] = row
+	return nil
+}
+
+func (r *returnRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	row, ok := t.returns[id]
+	if !ok || row.Status != from {
+		return 0, nil
+	}
+
+	row.Status = to
+	row.UpdatedAt = time.Now()
+	t.returns[id] = row
+	return 1, nil
+}
+
+func (r *returnRepository) ReturnedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	returned := make(map[uint]int)
+	for _, ret := range t.returns {
+		if ret.OrderID != orderID || !hasStatus(ret.Status, statuses) {
+			continue
+		}
+		for _, item := range ret.Items {
+			returned[item.OrderItemID] += item.Quantity
+		}
+	}
+	return returned, nil
+}
+
+// copyReturn copies ret so callers never share its items with the store.
+func copyReturn(ret *domain.Return) *domain.Return {
+	c := *ret
+	c.Items = append([]domain.ReturnItem(nil), ret.Items...)
+	c.RefundID = copyID(ret.RefundID)
+	return &c
+}
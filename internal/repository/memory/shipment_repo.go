@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"sort"
+	"time"
+)
+
+type shipmentRepository struct {
+	base
+}
+
+func NewShipmentRepository(store *Store) *shipmentRepository {
+	return &shipmentRepository{base{store: store}}
+}
+
+func (r *shipmentRepository) Create(ctx context.Context, shipment *domain.Shipment) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.orders[shipment.OrderID]; !ok {
+		return foreignKey("shipments", "order_id")
+	}
+	seen := make(map[uint]bool, len(shipment.Items))
+	for _, item := range shipment.Items {
+		if _, ok := t.orderItems[item.OrderItemID]; !ok {
+			return foreignKey("shipment_items", "order_item_id")
+		}
+		if seen[item.OrderItemID] {
+			return duplicateEntry(item.OrderItemID, "unique_shipment_order_item")
+		}
+		seen[item.OrderItemID] = true
+	}
+
+	now := time.Now()
+	shipment.ID = t.newID("shipments")
+	shipment.CreatedAt = now
+	shipment.UpdatedAt = now
+	for i := range shipment.Items {
+		shipment.Items[i].ID = t.newID("shipment_items")
+		shipment.Items[i].ShipmentID = shipment.ID
+	}
+	t.shipments[shipment.ID] = *copyShipment(shipment)
+	return nil
+}
+
+func (r *shipmentRepository) GetByID(ctx context.Context, id uint) (*domain.Shipment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shipment, ok := t.shipments[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return copyShipment(&shipment), nil
+}
+
+func (r *shipmentRepository) GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Shipment, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var shipments []*domain.Shipment
+	for _, shipment := range t.shipments {
+		if shipment.OrderID == orderID {
+			shipments = append(shipments, copyShipment(&shipment))
+		}
+	}
+	sort.Slice(shipments, func(i, j int) bool { return shipments[i].ID < shipments[j].ID })
+	return shipments, nil
+}
+
+func (r *shipmentRepository) Update(ctx context.Context, shipment *domain.Shipment) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.shipments[shipment.ID]
+	if !ok {
+		return errNotFound
+	}
+	shipment.UpdatedAt = time.Now()
+	row.Carrier = shipment.Carrier
+	row.TrackingNumber = shipment.TrackingNumber
+	row.Status = shipment.Status
+	row.ShippedAt = copyTime(shipment.ShippedAt)
+	row.DeliveredAt = copyTime(shipment.DeliveredAt)
+	row.UpdatedAt = shipment.UpdatedAt
+	t.shipments[shipment.ID] = row
+	return nil
+}
+
+func (r *shipmentRepository) ShippedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shipped := make(map[uint]int)
+	for _, shipment := range t.shipments {
+		if shipment.OrderID != orderID || !hasStatus(shipment.Status, statuses) {
+			continue
+		}
+		for _, item := range shipment.Items {
+			shipped[item.OrderItemID] += item.Quantity
+		}
+	}
+	return shipped, nil
+}
+
+// copyShipment copies shipment so callers never share its items or
+// timestamps with the store.
+func copyShipment(shipment *domain.Shipment) *domain.Shipment {
+	c := *shipment
+	c.Items = append([]domain.ShipmentItem(nil), shipment.Items...)
+	c.ShippedAt = copyTime(shipment.ShippedAt)
+	c.DeliveredAt = copyTime(shipment.DeliveredAt)
+	return &c
+}
+
+// copyTime copies an optional timestamp so rows never share it with callers.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
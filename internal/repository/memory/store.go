@@ -0,0 +1,194 @@
+// Package memory implements the repository interfaces on top of in-process
+// maps. It mirrors the behaviour of the MySQL repositories, including unique
+// keys and domain.ErrNotFound for missing rows, so usecases can run without a
+// database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"sync"
+)
+
+// errNotFound is what the SQL repositories return for missing rows.
+var errNotFound = domain.ErrNotFound
+
+func duplicateEntry(value interface{}, key string) error {
+	return fmt.Errorf("%w: duplicate entry '%v' for key '%s'", domain.ErrConflict, value, key)
+}
+
+func foreignKey(table, column string) error {
+	return fmt.Errorf("%w: foreign key constraint fails on %s.%s", domain.ErrConflict, table, column)
+}
+
+type cartKey struct {
+	userID    uint
+	productID uint
+}
+
+type cartRow struct {
+	id   uint
+	item domain.CartItems
+}
+
+type tables struct {
+	users      map[uint]domain.User
+	products   map[uint]domain.Product
+	orders     map[uint]domain.Order
+	orderItems map[uint]domain.OrderItem
+	cartItems  map[cartKey]cartRow
+	payments   map[uint]domain.Payment
+	// orderHistory is keyed by ID.
+	orderHistory map[uint]domain.OrderStatusHistory
+	// orderAddresses are keyed by ID.
+	orderAddresses map[uint]domain.OrderAddress
+	// addresses are keyed by ID.
+	addresses map[uint]domain.Address
+	// reservations are keyed by ID.
+	reservations map[uint]domain.StockReservation
+	// refunds are keyed by ID and hold their items.
+	refunds map[uint]domain.Refund
+	// shipments are keyed by ID and hold their items.
+	shipments map[uint]domain.Shipment
+	// returns are keyed by ID and hold their items.
+	returns map[uint]domain.Return
+
+	nextID map[string]uint
+}
+
+func newTables() *tables {
+	return &tables{
+		users:          make(map[uint]domain.User),
+		products:       make(map[uint]domain.Product),
+		orders:         make(map[uint]domain.Order),
+		orderItems:     make(map[uint]domain.OrderItem),
+		orderHistory:   make(map[uint]domain.OrderStatusHistory),
+		orderAddresses: make(map[uint]domain.OrderAddress),
+		addresses:      make(map[uint]domain.Address),
+		cartItems:      make(map[cartKey]cartRow),
+		payments:       make(map[uint]domain.Payment),
+		reservations:   make(map[uint]domain.StockReservation),
+		refunds:        make(map[uint]domain.Refund),
+		shipments:      make(map[uint]domain.Shipment),
+		returns:        make(map[uint]domain.Return),
+		nextID:         make(map[string]uint),
+	}
+}
+
+// clone copies the tables for a unit of work to roll back to. Rows that hold
+// item slices are copied deeply so that a rolled back write cannot reach the
+// snapshot through them.
+func (t *tables) clone() *tables {
+	c := newTables()
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	for k, v := range t.products {
+		c.products[k] = v
+	}
+	for k, v := range t.orders {
+		c.orders[k] = v
+	}
+	for k, v := range t.orderItems {
+		c.orderItems[k] = v
+	}
+	for k, v := range t.orderHistory {
+		c.orderHistory[k] = v
+	}
+	for k, v := range t.orderAddresses {
+		c.orderAddresses[k] = v
+	}
+	for k, v := range t.addresses {
+		c.addresses[k] = v
+	}
+	for k, v := range t.cartItems {
+		c.cartItems[k] = v
+	}
+	for k, v := range t.payments {
+		c.payments[k] = v
+	}
+	for k, v := range t.reservations {
+		c.reservations[k] = v
+	}
+	for k, v := range t.refunds {
+		c.refunds[k] = *copyRefund(&v)
+	}
+	for k, v := range t.shipments {
+		c.shipments[k] = *copyShipment(&v)
+	}
+	for k, v := range t.returns {
+		c.returns[k] = *copyReturn(&v)
+	}
+	for k, v := range t.nextID {
+		c.nextID[k] = v
+	}
+	return c
+}
+
+// newID hands out auto increment IDs per table.
+func (t *tables) newID(table string) uint {
+	t.nextID[table]++
+	return t.nextID[table]
+}
+
+// Store holds all data. It is safe for concurrent use.
+type Store struct {
+	// txMu lets a unit of work run alone: plain repository calls hold it for
+	// reading, a unit of work holds it for writing.
+	txMu sync.RWMutex
+	mu   sync.Mutex
+	data *tables
+}
+
+func NewStore() *Store {
+	return &Store{data: newTables()}
+}
+
+// Repositories returns repositories backed by s.
+func (s *Store) Repositories() repository.Repositories {
+	return s.repositories(false)
+}
+
+func (s *Store) repositories(inTx bool) repository.Repositories {
+	b := base{store: s, inTx: inTx}
+	return repository.Repositories{
+		Users:        &userRepository{b},
+		Products:     &productRepository{b},
+		Orders:       &orderRepository{b},
+		Carts:        &cartItemRepository{b},
+		Payments:     &paymentRepository{b},
+		Reservations: &reservationRepository{b},
+		Refunds:      &refundRepository{b},
+		Shipments:    &shipmentRepository{b},
+		Returns:      &returnRepository{b},
+		Addresses:    &addressRepository{b},
+	}
+}
+
+// base is embedded in every repository.
+type base struct {
+	store *Store
+	inTx  bool
+}
+
+// lock gives exclusive access to the tables until the returned func is
+// called. Like a database call it fails once ctx is done.
+func (b base) lock(ctx context.Context) (*tables, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if !b.inTx {
+		b.store.txMu.RLock()
+	}
+	b.store.mu.Lock()
+
+	return b.store.data, func() {
+		b.store.mu.Unlock()
+		if !b.inTx {
+			b.store.txMu.RUnlock()
+		}
+	}, nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/repository"
+)
+
+type unitOfWork struct {
+	store *Store
+}
+
+func NewUnitOfWork(store *Store) *unitOfWork {
+	return &unitOfWork{store: store}
+}
+
+// Do runs fn while no other repository call can touch the store and restores
+// the previous state when fn fails.
+func (u *unitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	u.store.txMu.Lock()
+	defer u.store.txMu.Unlock()
+
+	u.store.mu.Lock()
+	snapshot := u.store.data.clone()
+	u.store.mu.Unlock()
+
+	rollback := func() {
+		u.store.mu.Lock()
+		u.store.data = snapshot
+		u.store.mu.Unlock()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(u.store.repositories(true)); err != nil {
+		rollback()
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		rollback()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"shop/internal/domain"
+	"time"
+)
+
+type userRepository struct {
+	base
+}
+
+func NewUserRepository(store *Store) *userRepository {
+	return &userRepository{base{store: store}}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.checkUniqueUser(0, user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	row := domain.User{
+		ID:        t.newID("users"),
+		Username:  user.Username,
+		Email:     user.Email,
+		Password:  user.Password,
+		Role:      user.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if row.Role == "" {
+		row.Role = domain.RoleUser
+	}
+	t.users[row.ID] = row
+
+	user.ID = row.ID
+	return nil
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	user, ok := t.users[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	for _, user := range t.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.users[user.ID]
+	if !ok {
+		return errNotFound
+	}
+	if err := t.checkUniqueUser(user.ID, user); err != nil {
+		return err
+	}
+
+	row.Username = user.Username
+	row.Email = user.Email
+	row.Role = user.Role
+	row.TotalCart = user.TotalCart
+	row.UpdatedAt = time.Now()
+	t.users[row.ID] = row
+	return nil
+}
+
+func (r *userRepository) LockCart(ctx context.Context, userID, paymentID uint, expiresAt time.Time) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.users[userID]
+	if !ok {
+		return errNotFound
+	}
+	if row.CartLocked(time.Now()) {
+		return domain.ErrCartLocked
+	}
+
+	row.LockCart = true
+	row.LockPaymentID = &paymentID
+	row.LockExpiresAt = &expiresAt
+	t.users[userID] = row
+	return nil
+}
+
+func (r *userRepository) UnlockCart(ctx context.Context, userID, paymentID uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	row, ok := t.users[userID]
+	if !ok {
+		return errNotFound
+	}
+	if row.LockPaymentID == nil || *row.LockPaymentID != paymentID {
+		return domain.ErrConflict
+	}
+
+	row.LockCart = false
+	row.LockPaymentID = nil
+	row.LockExpiresAt = nil
+	t.users[userID] = row
+	return nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	t, unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, order := range t.orders {
+		if order.UserID == id {
+			return foreignKey("orders", "user_id")
+		}
+	}
+
+	delete(t.users, id)
+	return nil
+}
+
+// checkUniqueUser enforces the unique username and email keys, ignoring the
+// row with ID self.
+func (t *tables) checkUniqueUser(self uint, user *domain.User) error {
+	for id, other := range t.users {
+		if id == self {
+			continue
+		}
+		if other.Email == user.Email {
+			return duplicateEntry(user.Email, "email")
+		}
+		if other.Username == user.Username {
+			return duplicateEntry(user.Username, "username")
+		}
+	}
+	return nil
+}
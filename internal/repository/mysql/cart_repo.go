@@ -1,25 +1,26 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"shop/internal/domain"
 	"time"
 )
 
 type cartItemRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewCartRepository(db *sql.DB) *cartItemRepository {
 	return &cartItemRepository{db: db}
 }
 
-func (r *cartItemRepository) GetByUserID(userID uint) ([]domain.CartItems, error) {
+func (r *cartItemRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.CartItems, error) {
 	query := `SELECT product_id, quantity, fee, user_id, created_at, updated_at 
 	          FROM cart_items WHERE user_id = ?`
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	defer rows.Close()
 
@@ -35,46 +36,63 @@ func (r *cartItemRepository) GetByUserID(userID uint) ([]domain.CartItems, error
 			&item.UpdatedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		cartItems = append(cartItems, item)
 	}
 	return cartItems, nil
 }
 
-func (r *cartItemRepository) CreateCartItems(userID uint, item domain.CartItems) error {
+func (r *cartItemRepository) CreateCartItems(ctx context.Context, userID uint, item domain.CartItems) error {
 	query := `INSERT INTO cart_items (user_id, product_id, quantity, fee, created_at, updated_at) 
 	          VALUES (?, ?, ?, ?, ?, ?)`
 	now := time.Now()
-	_, err := r.db.Exec(query, userID, item.ProductId, item.Quantity, item.Fee, now, now)
-	return err
+	_, err := r.db.ExecContext(ctx, query, userID, item.ProductId, item.Quantity, item.Fee, now, now)
+	return translateError(err)
 }
 
-func (r *cartItemRepository) UpdateCartItem(userID uint, item domain.CartItems) error {
+func (r *cartItemRepository) UpsertCartItem(ctx context.Context, userID uint, item domain.CartItems, replace bool) error {
+	quantity := "quantity + VALUES(quantity)"
+	if replace {
+		quantity = "VALUES(quantity)"
+	}
+	query := `INSERT INTO cart_items (user_id, product_id, quantity, fee, created_at, updated_at) 
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE quantity = ` + quantity + `, fee = VALUES(fee), updated_at = VALUES(updated_at)`
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, query, userID, item.ProductId, item.Quantity, item.Fee, now, now)
+	return translateError(err)
+}
+
+func (r *cartItemRepository) Update(ctx context.Context, cartItem *domain.CartItems) error {
+	return r.UpdateCartItem(ctx, cartItem.UserId, *cartItem)
+}
+
+func (r *cartItemRepository) UpdateCartItem(ctx context.Context, userID uint, item domain.CartItems) error {
 	query := `UPDATE cart_items SET quantity = ?, fee = ?, updated_at = ? 
 	          WHERE product_id = ? AND user_id = ?`
-	_, err := r.db.Exec(query, item.Quantity, item.Fee, time.Now(), item.ProductId, userID)
-	return err
+	_, err := r.db.ExecContext(ctx, query, item.Quantity, item.Fee, time.Now(), item.ProductId, userID)
+	return translateError(err)
 }
 
-func (r *cartItemRepository) DeleteCartItem(userID, productID uint) error {
+func (r *cartItemRepository) DeleteCartItem(ctx context.Context, userID, productID uint) error {
 	query := `DELETE FROM cart_items WHERE user_id = ? AND product_id = ?`
-	_, err := r.db.Exec(query, userID, productID)
-	return err
+	_, err := r.db.ExecContext(ctx, query, userID, productID)
+	return translateError(err)
 }
 
-func (r *cartItemRepository) ClearCart(userID uint) error {
+func (r *cartItemRepository) ClearCart(ctx context.Context, userID uint) error {
 	query := `DELETE FROM cart_items WHERE user_id = ?`
-	_, err := r.db.Exec(query, userID)
-	return err
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return translateError(err)
 }
 
-func (r *cartItemRepository) GetCartItemByUserAndProduct(userID, productID uint) (*domain.CartItems, error) {
+func (r *cartItemRepository) GetCartItemByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.CartItems, error) {
 	query := `SELECT product_id, quantity, fee, user_id, created_at, updated_at 
 	          FROM cart_items WHERE user_id = ? AND product_id = ?`
 
 	item := &domain.CartItems{}
-	err := r.db.QueryRow(query, userID, productID).Scan(
+	err := r.db.QueryRowContext(ctx, query, userID, productID).Scan(
 		&item.ProductId,
 		&item.Quantity,
 		&item.Fee,
@@ -84,7 +102,7 @@ func (r *cartItemRepository) GetCartItemByUserAndProduct(userID, productID uint)
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return item, nil
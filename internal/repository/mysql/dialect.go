@@ -0,0 +1,14 @@
+package mysql
+
+import (
+	"shop/internal/repository/sqlrepo"
+	"time"
+)
+
+// dialect binds times as they are; MySQL compares DATETIME columns itself.
+var dialect = sqlrepo.Dialect{
+	Time: func(t time.Time) interface{} {
+		return t
+	},
+	TranslateError: translateError,
+}
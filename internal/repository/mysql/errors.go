@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"shop/internal/domain"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// MySQL server error numbers.
+const (
+	errDuplicateEntry   = 1062
+	errRowIsReferenced  = 1451
+	errNoReferencedRow  = 1452
+	errRowIsReferenced2 = 1217
+	errNoReferencedRow2 = 1216
+)
+
+// translateError turns driver errors into domain errors. The original error
+// stays in the chain for logging.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+
+	var mysqlErr *driver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case errDuplicateEntry, errRowIsReferenced, errRowIsReferenced2:
+			return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+		case errNoReferencedRow, errNoReferencedRow2:
+			return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+		}
+	}
+
+	return err
+}
@@ -1,55 +1,67 @@
 package mysql
 
 import (
+    "context"
     "database/sql"
     "shop/internal/domain"
+    "shop/internal/repository/sqlrepo"
+    "strings"
+    "time"
 )
 
 type orderRepository struct {
-    db *sql.DB
+    db dbtx
 }
 
 func NewOrderRepository(db *sql.DB) *orderRepository {
     return &orderRepository{db: db}
 }
 
-func (r *orderRepository) Create(order *domain.Order) error {
-    query := `INSERT INTO orders (user_id, total, status) VALUES (?, ?, ?)`
-    result, err := r.db.Exec(query, order.UserID, order.Total, order.Status)
+const orderColumns = `id, user_id, total, status, shipping_method, shipping_cost, discount_code, discount, tax,
+    created_at, updated_at`
+
+func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
+    query := `INSERT INTO orders (user_id, total, status, shipping_method, shipping_cost, discount_code, discount, tax)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+    result, err := r.db.ExecContext(ctx, query,
+        order.UserID, order.Total, order.Status, order.ShippingMethod,
+        order.ShippingCost, order.DiscountCode, order.Discount, order.Tax,
+    )
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     id, err := result.LastInsertId()
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     order.ID = uint(id)
     return nil
 }
 
-func (r *orderRepository) GetByID(id uint) (*domain.Order, error) {
+func (r *orderRepository) GetByID(ctx context.Context, id uint) (*domain.Order, error) {
     order := &domain.Order{}
-    query := `SELECT id, user_id, total, status, created_at, updated_at FROM orders WHERE id = ?`
+    query := `SELECT ` + orderColumns + ` FROM orders WHERE id = ?`
     
-    err := r.db.QueryRow(query, id).Scan(
-        &order.ID, &order.UserID, &order.Total, &order.Status,
+    err := r.db.QueryRowContext(ctx, query, id).Scan(
+        &order.ID, &order.UserID, &order.Total, &order.Status, &order.ShippingMethod,
+        &order.ShippingCost, &order.DiscountCode, &order.Discount, &order.Tax,
         &order.CreatedAt, &order.UpdatedAt,
     )
     
     if err != nil {
-        return nil, err
+        return nil, translateError(err)
     }
     
     return order, nil
 }
 
-func (r *orderRepository) GetByUserID(userID uint, limit, offset int) ([]*domain.Order, error) {
-    query := `SELECT id, user_id, total, status, created_at, updated_at FROM orders WHERE user_id = ? LIMIT ? OFFSET ?`
-    rows, err := r.db.Query(query, userID, limit, offset)
+func (r *orderRepository) GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Order, error) {
+    query := `SELECT ` + orderColumns + ` FROM orders WHERE user_id = ? LIMIT ? OFFSET ?`
+    rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
     if err != nil {
-        return nil, err
+        return nil, translateError(err)
     }
     defer rows.Close()
 
@@ -57,11 +69,12 @@ func (r *orderRepository) GetByUserID(userID uint, limit, offset int) ([]*domain
     for rows.Next() {
         order := &domain.Order{}
         err := rows.Scan(
-            &order.ID, &order.UserID, &order.Total, &order.Status,
+            &order.ID, &order.UserID, &order.Total, &order.Status, &order.ShippingMethod,
+            &order.ShippingCost, &order.DiscountCode, &order.Discount, &order.Tax,
             &order.CreatedAt, &order.UpdatedAt,
         )
         if err != nil {
-            return nil, err
+            return nil, translateError(err)
         }
         orders = append(orders, order)
     }
@@ -69,29 +82,29 @@ func (r *orderRepository) GetByUserID(userID uint, limit, offset int) ([]*domain
     return orders, nil
 }
 
-func (r *orderRepository) Update(order *domain.Order) error {
-    query := `UPDATE orders SET status = ?, total = ? WHERE id = ?`
-    _, err := r.db.Exec(query, order.Status, order.Total, order.ID)
-    return err
+func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
+    query := `UPDATE orders SET total = ? WHERE id = ?`
+    _, err := r.db.ExecContext(ctx, query, order.Total, order.ID)
+    return translateError(err)
 }
 
-func (r *orderRepository) CreateOrderItem(item *domain.OrderItem) error {
+func (r *orderRepository) CreateOrderItem(ctx context.Context, item *domain.OrderItem) error {
     query := `INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)`
-    result, err := r.db.Exec(query, item.OrderID, item.ProductID, item.Quantity, item.Price)
+    result, err := r.db.ExecContext(ctx, query, item.OrderID, item.ProductID, item.Quantity, item.Price)
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     id, err := result.LastInsertId()
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     item.ID = uint(id)
     return nil
 }
 
-func (r *orderRepository) GetOrderItems(orderID uint) ([]*domain.OrderItem, error) {
+func (r *orderRepository) GetOrderItems(ctx context.Context, orderID uint) ([]*domain.OrderItem, error) {
     query := `
         SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price,
                p.id, p.name, p.description, p.price, p.stock, p.category, p.created_at, p.updated_at
@@ -99,9 +112,9 @@ func (r *orderRepository) GetOrderItems(orderID uint) ([]*domain.OrderItem, erro
         JOIN products p ON oi.product_id = p.id
         WHERE oi.order_id = ?`
     
-    rows, err := r.db.Query(query, orderID)
+    rows, err := r.db.QueryContext(ctx, query, orderID)
     if err != nil {
-        return nil, err
+        return nil, translateError(err)
     }
     defer rows.Close()
 
@@ -115,10 +128,208 @@ func (r *orderRepository) GetOrderItems(orderID uint) ([]*domain.OrderItem, erro
             &item.Product.CreatedAt, &item.Product.UpdatedAt,
         )
         if err != nil {
-            return nil, err
+            return nil, translateError(err)
         }
         items = append(items, item)
     }
 
     return items, nil
-}
\ No newline at end of file
+}
+
+func (r *orderRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+    query := `UPDATE orders SET status = ? WHERE id = ? AND status = ?`
+    result, err := r.db.ExecContext(ctx, query, to, id, from)
+    if err != nil {
+        return 0, translateError(err)
+    }
+
+    n, err := result.RowsAffected()
+    if err != nil {
+        return 0, translateError(err)
+    }
+    return n, nil
+}
+
+func (r *orderRepository) AddStatusHistory(ctx context.Context, entry *domain.OrderStatusHistory) error {
+    query := `INSERT INTO order_status_history (order_id, from_status, to_status, actor, changed_by, reason, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+    now := time.Now()
+    result, err := r.db.ExecContext(ctx, query,
+        entry.OrderID, entry.FromStatus, entry.ToStatus, entry.Actor, entry.ChangedBy, entry.Reason, now,
+    )
+    if err != nil {
+        return translateError(err)
+    }
+
+    id, err := result.LastInsertId()
+    if err != nil {
+        return translateError(err)
+    }
+
+    entry.ID = uint(id)
+    entry.CreatedAt = now
+    return nil
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID uint) ([]domain.OrderStatusHistory, error) {
+    query := `SELECT id, order_id, from_status, to_status, actor, changed_by, reason, created_at
+              FROM order_status_history WHERE order_id = ? ORDER BY id`
+
+    rows, err := r.db.QueryContext(ctx, query, orderID)
+    if err != nil {
+        return nil, translateError(err)
+    }
+    defer rows.Close()
+
+    var history []domain.OrderStatusHistory
+    for rows.Next() {
+        var entry domain.OrderStatusHistory
+        var changedBy sql.NullInt64
+        err := rows.Scan(
+            &entry.ID, &entry.OrderID, &entry.FromStatus, &entry.ToStatus,
+            &entry.Actor, &changedBy, &entry.Reason, &entry.CreatedAt,
+        )
+        if err != nil {
+            return nil, translateError(err)
+        }
+        if changedBy.Valid {
+            id := uint(changedBy.Int64)
+            entry.ChangedBy = &id
+        }
+        history = append(history, entry)
+    }
+
+    return history, translateError(rows.Err())
+}
+
+func (r *orderRepository) AddAddress(ctx context.Context, address *domain.OrderAddress) error {
+    query := `INSERT INTO order_addresses (order_id, kind, address_id, full_name, phone, line1, line2, city, state, postal_code, country, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+    now := time.Now()
+    result, err := r.db.ExecContext(ctx, query,
+        address.OrderID, address.Kind, address.AddressID, address.FullName, address.Phone, address.Line1,
+        address.Line2, address.City, address.State, address.PostalCode, address.Country, now,
+    )
+    if err != nil {
+        return translateError(err)
+    }
+
+    id, err := result.LastInsertId()
+    if err != nil {
+        return translateError(err)
+    }
+
+    address.ID = uint(id)
+    address.CreatedAt = now
+    return nil
+}
+
+func (r *orderRepository) GetAddresses(ctx context.Context, orderID uint) ([]domain.OrderAddress, error) {
+    query := `SELECT id, order_id, kind, address_id, full_name, phone, line1, line2, city, state, postal_code, country, created_at
+              FROM order_addresses WHERE order_id = ? ORDER BY id`
+
+    rows, err := r.db.QueryContext(ctx, query, orderID)
+    if err != nil {
+        return nil, translateError(err)
+    }
+    defer rows.Close()
+
+    var addresses []domain.OrderAddress
+    for rows.Next() {
+        var address domain.OrderAddress
+        var addressID sql.NullInt64
+        err := rows.Scan(
+            &address.ID, &address.OrderID, &address.Kind, &addressID, &address.FullName, &address.Phone,
+            &address.Line1, &address.Line2, &address.City, &address.State, &address.PostalCode,
+            &address.Country, &address.CreatedAt,
+        )
+        if err != nil {
+            return nil, translateError(err)
+        }
+        address.AddressID = sqlrepo.NullID(addressID)
+        addresses = append(addresses, address)
+    }
+
+    return addresses, translateError(rows.Err())
+}
+
+// orderSortColumns maps the sort keys of domain.OrderFilter to columns.
+var orderSortColumns = map[string]string{
+    domain.OrderSortID:        "id",
+    domain.OrderSortCreatedAt: "created_at",
+    domain.OrderSortTotal:     "total",
+}
+
+func (r *orderRepository) List(ctx context.Context, filter domain.OrderFilter) ([]*domain.Order, int, error) {
+    var where []string
+    var args []interface{}
+    if filter.Status != "" {
+        where = append(where, "status = ?")
+        args = append(args, filter.Status)
+    }
+    if filter.UserID != 0 {
+        where = append(where, "user_id = ?")
+        args = append(args, filter.UserID)
+    }
+    if filter.CreatedFrom != nil {
+        where = append(where, "created_at >= ?")
+        args = append(args, *filter.CreatedFrom)
+    }
+    if filter.CreatedTo != nil {
+        where = append(where, "created_at < ?")
+        args = append(args, *filter.CreatedTo)
+    }
+    if filter.MinTotal != nil {
+        where = append(where, "total >= ?")
+        args = append(args, *filter.MinTotal)
+    }
+    if filter.MaxTotal != nil {
+        where = append(where, "total <= ?")
+        args = append(args, *filter.MaxTotal)
+    }
+
+    clause := ""
+    if len(where) > 0 {
+        clause = " WHERE " + strings.Join(where, " AND ")
+    }
+
+    var total int
+    if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`+clause, args...).Scan(&total); err != nil {
+        return nil, 0, translateError(err)
+    }
+
+    column, ok := orderSortColumns[filter.SortBy]
+    if !ok {
+        column = "created_at"
+    }
+    direction := "ASC"
+    if filter.Descending {
+        direction = "DESC"
+    }
+
+    query := `SELECT ` + orderColumns + ` FROM orders` + clause +
+        ` ORDER BY ` + column + ` ` + direction + `, id ` + direction + ` LIMIT ? OFFSET ?`
+    rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+    if err != nil {
+        return nil, 0, translateError(err)
+    }
+    defer rows.Close()
+
+    var orders []*domain.Order
+    for rows.Next() {
+        order := &domain.Order{}
+        err := rows.Scan(
+            &order.ID, &order.UserID, &order.Total, &order.Status, &order.ShippingMethod,
+            &order.ShippingCost, &order.DiscountCode, &order.Discount, &order.Tax,
+            &order.CreatedAt, &order.UpdatedAt,
+        )
+        if err != nil {
+            return nil, 0, translateError(err)
+        }
+        orders = append(orders, order)
+    }
+
+    return orders, total, translateError(rows.Err())
+}
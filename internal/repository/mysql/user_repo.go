@@ -1,74 +1,127 @@
 package mysql
 
 import (
+    "context"
     "database/sql"
     "shop/internal/domain"
+    "time"
 )
 
 type userRepository struct {
-    db *sql.DB
+    db dbtx
 }
 
 func NewUserRepository(db *sql.DB) *userRepository {
     return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *domain.User) error {
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
     query := `INSERT INTO users (username, email, password, role) VALUES (?, ?, ?, ?)`
-    result, err := r.db.Exec(query, user.Username, user.Email, user.Password, user.Role)
+    result, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.Password, user.Role)
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     id, err := result.LastInsertId()
     if err != nil {
-        return err
+        return translateError(err)
     }
     
     user.ID = uint(id)
     return nil
 }
 
-func (r *userRepository) GetByID(id uint) (*domain.User, error) {
+const userColumns = `id, username, email, password, role, total_cart, lock_cart, lock_payment_id, lock_expires_at, created_at, updated_at`
+
+func scanUser(row interface{ Scan(dest ...interface{}) error }) (*domain.User, error) {
     user := &domain.User{}
-    query := `SELECT id, username, email, password, role, created_at, updated_at FROM users WHERE id = ?`
-    
-    err := r.db.QueryRow(query, id).Scan(
-        &user.ID, &user.Username, &user.Email, &user.Password,
-        &user.Role, &user.CreatedAt, &user.UpdatedAt,
+    var lockPaymentID sql.NullInt64
+    var lockExpiresAt sql.NullTime
+
+    err := row.Scan(
+        &user.ID, &user.Username, &user.Email, &user.Password, &user.Role,
+        &user.TotalCart, &user.LockCart, &lockPaymentID, &lockExpiresAt,
+        &user.CreatedAt, &user.UpdatedAt,
     )
-    
     if err != nil {
-        return nil, err
+        return nil, translateError(err)
     }
-    
+
+    if lockPaymentID.Valid {
+        id := uint(lockPaymentID.Int64)
+        user.LockPaymentID = &id
+    }
+    if lockExpiresAt.Valid {
+        user.LockExpiresAt = &lockExpiresAt.Time
+    }
+
     return user, nil
 }
 
-func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
-    user := &domain.User{}
-    query := `SELECT id, username, email, password, role, created_at, updated_at FROM users WHERE email = ?`
-    
-    err := r.db.QueryRow(query, email).Scan(
-        &user.ID, &user.Username, &user.Email, &user.Password,
-        &user.Role, &user.CreatedAt, &user.UpdatedAt,
-    )
-    
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+    query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+    return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+    query := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+    return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
+    query := `UPDATE users SET username = ?, email = ?, role = ?, total_cart = ? WHERE id = ?`
+    _, err := r.db.ExecContext(ctx, query, user.Username, user.Email, user.Role, user.TotalCart, user.ID)
+    return translateError(err)
+}
+
+func (r *userRepository) LockCart(ctx context.Context, userID, paymentID uint, expiresAt time.Time) error {
+    query := `UPDATE users SET lock_cart = TRUE, lock_payment_id = ?, lock_expires_at = ?
+              WHERE id = ? AND (lock_cart = FALSE OR lock_expires_at IS NULL OR lock_expires_at <= ?)`
+    result, err := r.db.ExecContext(ctx, query, paymentID, expiresAt, userID, time.Now())
     if err != nil {
-        return nil, err
+        return translateError(err)
     }
-    
-    return user, nil
+
+    n, err := result.RowsAffected()
+    if err != nil {
+        return translateError(err)
+    }
+    if n == 0 {
+        return r.missingOr(ctx, userID, domain.ErrCartLocked)
+    }
+    return nil
+}
+
+func (r *userRepository) UnlockCart(ctx context.Context, userID, paymentID uint) error {
+    query := `UPDATE users SET lock_cart = FALSE, lock_payment_id = NULL, lock_expires_at = NULL
+              WHERE id = ? AND lock_payment_id = ?`
+    result, err := r.db.ExecContext(ctx, query, userID, paymentID)
+    if err != nil {
+        return translateError(err)
+    }
+
+    n, err := result.RowsAffected()
+    if err != nil {
+        return translateError(err)
+    }
+    if n == 0 {
+        return r.missingOr(ctx, userID, domain.ErrConflict)
+    }
+    return nil
 }
 
-func (r *userRepository) Update(user *domain.User) error {
-    query := `UPDATE users SET username = ?, email = ?, role = ? WHERE id = ?`
-    _, err := r.db.Exec(query, user.Username, user.Email, user.Role, user.ID)
+// missingOr returns domain.ErrNotFound when the user does not exist and err
+// otherwise, to explain an update that matched no rows.
+func (r *userRepository) missingOr(ctx context.Context, userID uint, err error) error {
+    var id uint
+    if scanErr := r.db.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ?`, userID).Scan(&id); scanErr != nil {
+        return translateError(scanErr)
+    }
     return err
 }
 
-func (r *userRepository) Delete(id uint) error {
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
     query := `DELETE FROM users WHERE id = ?`
-    _, err := r.db.Exec(query, id)
-    return err
+    _, err := r.db.ExecContext(ctx, query, id)
+    return translateError(err)
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"time"
+)
+
+type cartItemRepository struct {
+	db dbtx
+}
+
+func NewCartRepository(db *sql.DB) *cartItemRepository {
+	return &cartItemRepository{db: db}
+}
+
+func (r *cartItemRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.CartItems, error) {
+	query := `SELECT product_id, quantity, fee, user_id, created_at, updated_at 
+	          FROM cart_items WHERE user_id = ?`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var cartItems []domain.CartItems
+	for rows.Next() {
+		item := domain.CartItems{}
+		err := rows.Scan(
+			&item.ProductId,
+			&item.Quantity,
+			&item.Fee,
+			&item.UserId,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		cartItems = append(cartItems, item)
+	}
+	return cartItems, nil
+}
+
+func (r *cartItemRepository) CreateCartItems(ctx context.Context, userID uint, item domain.CartItems) error {
+	query := `INSERT INTO cart_items (user_id, product_id, quantity, fee, created_at, updated_at) 
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, query, userID, item.ProductId, item.Quantity, item.Fee, dialect.Time(now), dialect.Time(now))
+	return translateError(err)
+}
+
+func (r *cartItemRepository) UpsertCartItem(ctx context.Context, userID uint, item domain.CartItems, replace bool) error {
+	quantity := "cart_items.quantity + excluded.quantity"
+	if replace {
+		quantity = "excluded.quantity"
+	}
+	query := `INSERT INTO cart_items (user_id, product_id, quantity, fee, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (user_id, product_id) DO UPDATE SET quantity = ` + quantity + `, fee = excluded.fee, updated_at = excluded.updated_at`
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, query, userID, item.ProductId, item.Quantity, item.Fee, dialect.Time(now), dialect.Time(now))
+	return translateError(err)
+}
+
+func (r *cartItemRepository) Update(ctx context.Context, cartItem *domain.CartItems) error {
+	return r.UpdateCartItem(ctx, cartItem.UserId, *cartItem)
+}
+
+func (r *cartItemRepository) UpdateCartItem(ctx context.Context, userID uint, item domain.CartItems) error {
+	query := `UPDATE cart_items SET quantity = ?, fee = ?, updated_at = ? 
+	          WHERE product_id = ? AND user_id = ?`
+	_, err := r.db.ExecContext(ctx, query, item.Quantity, item.Fee, dialect.Time(time.Now()), item.ProductId, userID)
+	return translateError(err)
+}
+
+func (r *cartItemRepository) DeleteCartItem(ctx context.Context, userID, productID uint) error {
+	query := `DELETE FROM cart_items WHERE user_id = ? AND product_id = ?`
+	_, err := r.db.ExecContext(ctx, query, userID, productID)
+	return translateError(err)
+}
+
+func (r *cartItemRepository) ClearCart(ctx context.Context, userID uint) error {
+	query := `DELETE FROM cart_items WHERE user_id = ?`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return translateError(err)
+}
+
+func (r *cartItemRepository) GetCartItemByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.CartItems, error) {
+	query := `SELECT product_id, quantity, fee, user_id, created_at, updated_at 
+	          FROM cart_items WHERE user_id = ? AND product_id = ?`
+
+	item := &domain.CartItems{}
+	err := r.db.QueryRowContext(ctx, query, userID, productID).Scan(
+		&item.ProductId,
+		&item.Quantity,
+		&item.Fee,
+		&item.UserId,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,20 @@
+package sqlite
+
+import (
+	"shop/internal/repository/sqlrepo"
+	"time"
+)
+
+// timeLayout is how times are stored: UTC with a fixed number of fractional
+// digits. Stored times then sort as text in the order of the instants they
+// hold, and the CURRENT_TIMESTAMP defaults of the schema, which are the same
+// layout without the fraction, sort among them. That lets queries compare
+// time columns with plain operators, as they do on MySQL.
+const timeLayout = "2006-01-02 15:04:05.000000000"
+
+var dialect = sqlrepo.Dialect{
+	Time: func(t time.Time) interface{} {
+		return t.UTC().Format(timeLayout)
+	},
+	TranslateError: translateError,
+}
@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"shop/internal/domain"
+
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// translateError turns driver errors into domain errors. The original error
+// stays in the chain for logging.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+
+	var sqliteErr *driver.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+		}
+	}
+
+	return err
+}
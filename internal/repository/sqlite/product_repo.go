@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"time"
+)
+
+type productRepository struct {
+	db dbtx
+}
+
+func NewProductRepository(db *sql.DB) *productRepository {
+	return &productRepository{db: db}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *domain.Product) error {
+	query := `INSERT INTO products (name, description, price, stock, category) VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Stock, product.Category)
+	if err != nil {
+		return translateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return translateError(err)
+	}
+
+	product.ID = uint(id)
+	return nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id uint) (*domain.Product, error) {
+	product := &domain.Product{}
+	query := `SELECT id, name, description, price, stock, category, created_at, updated_at FROM products WHERE id = ?`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&product.ID, &product.Name, &product.Description, &product.Price,
+		&product.Stock, &product.Category, &product.CreatedAt, &product.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return product, nil
+}
+
+func (r *productRepository) GetAll(ctx context.Context, limit, offset int) ([]*domain.Product, error) {
+	query := `SELECT id, name, description, price, stock, category, created_at, updated_at FROM products LIMIT ? OFFSET ?`
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var products []*domain.Product
+	for rows.Next() {
+		product := &domain.Product{}
+		err := rows.Scan(
+			&product.ID, &product.Name, &product.Description, &product.Price,
+			&product.Stock, &product.Category, &product.CreatedAt, &product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *domain.Product) error {
+	query := `UPDATE products SET name = ?, description = ?, price = ?, stock = ?, category = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Stock, product.Category, product.ID)
+	return translateError(err)
+}
+
+func (r *productRepository) Delete(ctx context.Context, id uint) error {
+	query := `DELETE FROM products WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return translateError(err)
+}
+
+func (r *productRepository) UpdateStock(ctx context.Context, id uint, stock int) error {
+	query := `UPDATE products SET stock = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, stock, id)
+	return translateError(err)
+}
+
+func (r *productRepository) DecrementStock(ctx context.Context, id uint, quantity int) (int64, error) {
+	query := `UPDATE products SET stock = stock - ?, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = ? AND stock - (
+	              SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations
+	              WHERE product_id = ? AND expires_at > ?
+	          ) >= ?`
+	return r.execStock(ctx, query, quantity, id, id, dialect.Time(time.Now()), quantity)
+}
+
+func (r *productRepository) IncrementStock(ctx context.Context, id uint, quantity int) (int64, error) {
+	query := `UPDATE products SET stock = stock + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	return r.execStock(ctx, query, quantity, id)
+}
+
+func (r *productRepository) execStock(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return n, nil
+}
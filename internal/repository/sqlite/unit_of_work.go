@@ -0,0 +1,69 @@
+// Package sqlite implements the repository interfaces on SQLite, so the whole
+// API can run from a single database file. SQLite has no ON UPDATE clause, so
+// the queries here set updated_at themselves. The repositories whose SQL is
+// the same as on MySQL live in sqlrepo and run here with dialect.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/repository"
+	"shop/internal/repository/sqlrepo"
+)
+
+// dbtx is the part of *sql.DB and *sql.Tx the repositories rely on, so the
+// same repository code runs inside and outside a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type unitOfWork struct {
+	db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *unitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(newRepositories(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// NewRepositories returns all repositories backed by db.
+func NewRepositories(db *sql.DB) repository.Repositories {
+	return newRepositories(db)
+}
+
+func newRepositories(db dbtx) repository.Repositories {
+	return repository.Repositories{
+		Users:        &userRepository{db: db},
+		Products:     &productRepository{db: db},
+		Orders:       &orderRepository{db: db},
+		Carts:        &cartItemRepository{db: db},
+		Payments:     sqlrepo.NewPaymentRepository(db, dialect),
+		Reservations: sqlrepo.NewReservationRepository(db, dialect),
+		Refunds:      sqlrepo.NewRefundRepository(db, dialect),
+		Shipments:    sqlrepo.NewShipmentRepository(db, dialect),
+		Returns:      sqlrepo.NewReturnRepository(db, dialect),
+		Addresses:    sqlrepo.NewAddressRepository(db, dialect),
+	}
+}
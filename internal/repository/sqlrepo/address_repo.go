@@ -0,0 +1,130 @@
+package sqlrepo
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"time"
+)
+
+type addressRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewAddressRepository(db DBTX, d Dialect) repository.AddressRepository {
+	return &addressRepository{db: db, d: d}
+}
+
+const addressColumns = `id, user_id, label, full_name, phone, line1, line2, city, state, postal_code, country,
+	default_shipping, default_billing, created_at, updated_at`
+
+func scanAddress(row interface {
+	Scan(dest ...interface{}) error
+}) (*domain.Address, error) {
+	address := &domain.Address{}
+	err := row.Scan(
+		&address.ID, &address.UserID, &address.Label, &address.FullName, &address.Phone,
+		&address.Line1, &address.Line2, &address.City, &address.State, &address.PostalCode,
+		&address.Country, &address.DefaultShipping, &address.DefaultBilling,
+		&address.CreatedAt, &address.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (r *addressRepository) Create(ctx context.Context, address *domain.Address) error {
+	query := `INSERT INTO addresses (user_id, label, full_name, phone, line1, line2, city, state, postal_code, country,
+	          default_shipping, default_billing, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		address.UserID, address.Label, address.FullName, address.Phone, address.Line1, address.Line2,
+		address.City, address.State, address.PostalCode, address.Country,
+		address.DefaultShipping, address.DefaultBilling, r.d.Time(now), r.d.Time(now),
+	)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	address.ID = uint(id)
+	address.CreatedAt = now
+	address.UpdatedAt = now
+	return nil
+}
+
+func (r *addressRepository) GetByID(ctx context.Context, id uint) (*domain.Address, error) {
+	query := `SELECT ` + addressColumns + ` FROM addresses WHERE id = ?`
+	address, err := scanAddress(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	return address, nil
+}
+
+func (r *addressRepository) GetByUserID(ctx context.Context, userID uint) ([]*domain.Address, error) {
+	query := `SELECT ` + addressColumns + ` FROM addresses WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	var addresses []*domain.Address
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, r.d.TranslateError(rows.Err())
+}
+
+func (r *addressRepository) Update(ctx context.Context, address *domain.Address) error {
+	query := `UPDATE addresses SET label = ?, full_name = ?, phone = ?, line1 = ?, line2 = ?, city = ?, state = ?,
+	          postal_code = ?, country = ?, default_shipping = ?, default_billing = ?, updated_at = ?
+	          WHERE id = ?`
+
+	address.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query,
+		address.Label, address.FullName, address.Phone, address.Line1, address.Line2, address.City,
+		address.State, address.PostalCode, address.Country, address.DefaultShipping, address.DefaultBilling,
+		r.d.Time(address.UpdatedAt), address.ID,
+	)
+	return r.d.TranslateError(err)
+}
+
+func (r *addressRepository) Delete(ctx context.Context, id uint) error {
+	// SQLite has no ALTER TABLE ... ADD CONSTRAINT, so payments carry no
+	// foreign key to addresses there; let go of the address here on every
+	// database instead.
+	for _, query := range []string{
+		`UPDATE payments SET shipping_address_id = NULL WHERE shipping_address_id = ?`,
+		`UPDATE payments SET billing_address_id = NULL WHERE billing_address_id = ?`,
+		`DELETE FROM addresses WHERE id = ?`,
+	} {
+		if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+			return r.d.TranslateError(err)
+		}
+	}
+	return nil
+}
+
+func (r *addressRepository) ClearDefault(ctx context.Context, userID uint, kind string) error {
+	column := "default_shipping"
+	if kind == domain.AddressKindBilling {
+		column = "default_billing"
+	}
+	query := `UPDATE addresses SET ` + column + ` = FALSE, updated_at = ? WHERE user_id = ? AND ` + column + ` = TRUE`
+	_, err := r.db.ExecContext(ctx, query, r.d.Time(time.Now()), userID)
+	return r.d.TranslateError(err)
+}
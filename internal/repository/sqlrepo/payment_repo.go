@@ -0,0 +1,195 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"time"
+)
+
+type paymentRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewPaymentRepository(db DBTX, d Dialect) repository.PaymentRepository {
+	return &paymentRepository{db: db, d: d}
+}
+
+const paymentColumns = `id, user_id, order_id, shipping_address_id, billing_address_id, amount, status, payment_method, gateway_transaction_id, gateway_response, created_at, updated_at`
+
+func scanPayment(row interface {
+	Scan(dest ...interface{}) error
+}) (*domain.Payment, error) {
+	payment := &domain.Payment{}
+	var orderID, shippingAddressID, billingAddressID sql.NullInt64
+	err := row.Scan(
+		&payment.ID,
+		&payment.UserID,
+		&orderID,
+		&shippingAddressID,
+		&billingAddressID,
+		&payment.Amount,
+		&payment.Status,
+		&payment.PaymentMethod,
+		&payment.GatewayTransactionID,
+		&payment.GatewayResponse,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payment.OrderID = NullID(orderID)
+	payment.ShippingAddressID = NullID(shippingAddressID)
+	payment.BillingAddressID = NullID(billingAddressID)
+	return payment, nil
+}
+
+func (r *paymentRepository) scanPayments(rows *sql.Rows) ([]*domain.Payment, error) {
+	defer rows.Close()
+
+	var payments []*domain.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, r.d.TranslateError(rows.Err())
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
+	query := `INSERT INTO payments (user_id, order_id, shipping_address_id, billing_address_id, amount, status, payment_method, gateway_transaction_id, gateway_response, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		payment.UserID,
+		payment.OrderID,
+		payment.ShippingAddressID,
+		payment.BillingAddressID,
+		payment.Amount,
+		payment.Status,
+		payment.PaymentMethod,
+		payment.GatewayTransactionID,
+		payment.GatewayResponse,
+		r.d.Time(now),
+		r.d.Time(now),
+	)
+
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	payment.ID = uint(id)
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+
+	return nil
+}
+
+func (r *paymentRepository) GetByID(ctx context.Context, id uint) (*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = ?`
+
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	return payment, nil
+}
+
+func (r *paymentRepository) GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	return r.scanPayments(rows)
+}
+
+func (r *paymentRepository) GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = ? ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	return r.scanPayments(rows)
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *domain.Payment) error {
+	query := `UPDATE payments SET order_id = ?, shipping_address_id = ?, billing_address_id = ?, amount = ?, status = ?, payment_method = ?, gateway_transaction_id = ?, gateway_response = ?, updated_at = ?
+	          WHERE id = ?`
+
+	payment.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query,
+		payment.OrderID,
+		payment.ShippingAddressID,
+		payment.BillingAddressID,
+		payment.Amount,
+		payment.Status,
+		payment.PaymentMethod,
+		payment.GatewayTransactionID,
+		payment.GatewayResponse,
+		r.d.Time(payment.UpdatedAt),
+		payment.ID,
+	)
+
+	return r.d.TranslateError(err)
+}
+
+func (r *paymentRepository) GetPendingPaymentByUserID(ctx context.Context, userID uint) (*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE user_id = ? AND status = 'pending' ORDER BY created_at DESC LIMIT 1`
+
+	payment, err := scanPayment(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	return payment, nil
+}
+
+func (r *paymentRepository) GetPendingBefore(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + `
+	          FROM payments WHERE status = 'pending' AND created_at < ? ORDER BY created_at, id LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, r.d.Time(cutoff), limit)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	return r.scanPayments(rows)
+}
+
+func (r *paymentRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	query := `UPDATE payments SET status = ?, updated_at = ? WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, to, r.d.Time(time.Now()), id, from)
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+	return n, nil
+}
+
+func (r *paymentRepository) SetGatewayTransactionID(ctx context.Context, id uint, transactionID string) error {
+	query := `UPDATE payments SET gateway_transaction_id = ?, updated_at = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, transactionID, r.d.Time(time.Now()), id)
+	return r.d.TranslateError(err)
+}
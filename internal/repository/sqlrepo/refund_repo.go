@@ -0,0 +1,212 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"strings"
+	"time"
+)
+
+type refundRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewRefundRepository(db DBTX, d Dialect) repository.RefundRepository {
+	return &refundRepository{db: db, d: d}
+}
+
+const refundColumns = `id, payment_id, order_id, amount, status, reason, restock, gateway_refund_id, COALESCE(gateway_response, ''), created_at, updated_at`
+
+func scanRefund(row interface {
+	Scan(dest ...interface{}) error
+}) (*domain.Refund, error) {
+	refund := &domain.Refund{}
+	var orderID sql.NullInt64
+	err := row.Scan(
+		&refund.ID, &refund.PaymentID, &orderID, &refund.Amount, &refund.Status,
+		&refund.Reason, &refund.Restock, &refund.GatewayRefundID, &refund.GatewayResponse,
+		&refund.CreatedAt, &refund.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if orderID.Valid {
+		id := uint(orderID.Int64)
+		refund.OrderID = &id
+	}
+	return refund, nil
+}
+
+func (r *refundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	query := `INSERT INTO refunds (payment_id, order_id, amount, status, reason, restock, gateway_refund_id, gateway_response, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		refund.PaymentID, refund.OrderID, refund.Amount, refund.Status, refund.Reason,
+		refund.Restock, refund.GatewayRefundID, refund.GatewayResponse, r.d.Time(now), r.d.Time(now),
+	)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	refund.ID = uint(id)
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+
+	itemQuery := `INSERT INTO refund_items (refund_id, order_item_id, product_id, quantity, amount) VALUES (?, ?, ?, ?, ?)`
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		item.RefundID = refund.ID
+		result, err := r.db.ExecContext(ctx, itemQuery, item.RefundID, item.OrderItemID, item.ProductID, item.Quantity, item.Amount)
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		item.ID = uint(id)
+	}
+	return nil
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id uint) (*domain.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE id = ?`
+	refund, err := scanRefund(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	if err := r.loadItems(ctx, []*domain.Refund{refund}); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (r *refundRepository) GetByPaymentID(ctx context.Context, paymentID uint) ([]*domain.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	var refunds []*domain.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		refunds = append(refunds, refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	rows.Close()
+
+	if err := r.loadItems(ctx, refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// loadItems fills in the items of refunds with a single query.
+func (r *refundRepository) loadItems(ctx context.Context, refunds []*domain.Refund) error {
+	if len(refunds) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*domain.Refund, len(refunds))
+	args := make([]interface{}, 0, len(refunds))
+	for _, refund := range refunds {
+		byID[refund.ID] = refund
+		args = append(args, refund.ID)
+	}
+	query := `SELECT id, refund_id, order_item_id, product_id, quantity, amount FROM refund_items
+	          WHERE refund_id IN (?` + strings.Repeat(", ?", len(refunds)-1) + `) ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.RefundItem
+		if err := rows.Scan(&item.ID, &item.RefundID, &item.OrderItemID, &item.ProductID, &item.Quantity, &item.Amount); err != nil {
+			return r.d.TranslateError(err)
+		}
+		refund := byID[item.RefundID]
+		refund.Items = append(refund.Items, item)
+	}
+	return r.d.TranslateError(rows.Err())
+}
+
+func (r *refundRepository) Update(ctx context.Context, refund *domain.Refund) error {
+	query := `UPDATE refunds SET status = ?, gateway_refund_id = ?, gateway_response = ?, updated_at = ? WHERE id = ?`
+
+	refund.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query,
+		refund.Status, refund.GatewayRefundID, refund.GatewayResponse, r.d.Time(refund.UpdatedAt), refund.ID,
+	)
+	return r.d.TranslateError(err)
+}
+
+func (r *refundRepository) TotalByPaymentID(ctx context.Context, paymentID uint, statuses ...string) (float64, error) {
+	if len(statuses) == 0 {
+		return 0, nil
+	}
+
+	args := []interface{}{paymentID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	query := `SELECT COALESCE(SUM(amount), 0) FROM refunds
+	          WHERE payment_id = ? AND status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+
+	var total float64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+	return total, nil
+}
+
+func (r *refundRepository) RefundedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	refunded := make(map[uint]int)
+	if len(statuses) == 0 {
+		return refunded, nil
+	}
+
+	args := []interface{}{orderID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	query := `SELECT ri.order_item_id, SUM(ri.quantity) FROM refund_items ri
+	          JOIN refunds r ON r.id = ri.refund_id
+	          WHERE r.order_id = ? AND r.status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)
+	          GROUP BY ri.order_item_id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderItemID uint
+		var quantity int
+		if err := rows.Scan(&orderItemID, &quantity); err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		refunded[orderItemID] = quantity
+	}
+	return refunded, r.d.TranslateError(rows.Err())
+}
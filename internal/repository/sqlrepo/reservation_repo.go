@@ -0,0 +1,86 @@
+package sqlrepo
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"strings"
+	"time"
+)
+
+type reservationRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewReservationRepository(db DBTX, d Dialect) repository.ReservationRepository {
+	return &reservationRepository{db: db, d: d}
+}
+
+func (r *reservationRepository) Reserve(ctx context.Context, res *domain.StockReservation) (int64, error) {
+	query := `INSERT INTO stock_reservations (payment_id, product_id, quantity, expires_at, created_at)
+	          SELECT ?, p.id, ?, ?, ? FROM products p
+	          WHERE p.id = ? AND p.stock - (
+	              SELECT COALESCE(SUM(h.quantity), 0) FROM stock_reservations h
+	              WHERE h.product_id = p.id AND h.expires_at > ?
+	          ) >= ?`
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		res.PaymentID, res.Quantity, r.d.Time(res.ExpiresAt), r.d.Time(now),
+		res.ProductID, r.d.Time(now), res.Quantity,
+	)
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+	if n > 0 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, r.d.TranslateError(err)
+		}
+		res.ID = uint(id)
+		res.CreatedAt = now
+	}
+	return n, nil
+}
+
+func (r *reservationRepository) HeldQuantities(ctx context.Context, productIDs []uint) (map[uint]int, error) {
+	held := make(map[uint]int)
+	if len(productIDs) == 0 {
+		return held, nil
+	}
+
+	args := []interface{}{r.d.Time(time.Now())}
+	for _, id := range productIDs {
+		args = append(args, id)
+	}
+	query := `SELECT product_id, SUM(quantity) FROM stock_reservations
+	          WHERE expires_at > ? AND product_id IN (?` + strings.Repeat(", ?", len(productIDs)-1) + `)
+	          GROUP BY product_id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID uint
+		var quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		held[productID] = quantity
+	}
+	return held, r.d.TranslateError(rows.Err())
+}
+
+func (r *reservationRepository) DeleteByPaymentID(ctx context.Context, paymentID uint) error {
+	query := `DELETE FROM stock_reservations WHERE payment_id = ?`
+	_, err := r.db.ExecContext(ctx, query, paymentID)
+	return r.d.TranslateError(err)
+}
@@ -0,0 +1,228 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"strings"
+	"time"
+)
+
+type returnRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewReturnRepository(db DBTX, d Dialect) repository.ReturnRepository {
+	return &returnRepository{db: db, d: d}
+}
+
+const returnColumns = `id, order_id, user_id, status, reason, note, restocked, refund_id, created_at, updated_at`
+
+func scanReturn(row interface {
+	Scan(dest ...interface{}) error
+}) (*domain.Return, error) {
+	ret := &domain.Return{}
+	var refundID sql.NullInt64
+	err := row.Scan(
+		&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Reason,
+		&ret.Note, &ret.Restocked, &refundID, &ret.CreatedAt, &ret.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if refundID.Valid {
+		id := uint(refundID.Int64)
+		ret.RefundID = &id
+	}
+	return ret, nil
+}
+
+func (r *returnRepository) Create(ctx context.Context, ret *domain.Return) error {
+	query := `INSERT INTO order_returns (order_id, user_id, status, reason, note, restocked, refund_id, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		ret.OrderID, ret.UserID, ret.Status, ret.Reason, ret.Note,
+		ret.Restocked, ret.RefundID, r.d.Time(now), r.d.Time(now),
+	)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	ret.ID = uint(id)
+	ret.CreatedAt = now
+	ret.UpdatedAt = now
+
+	itemQuery := `INSERT INTO order_return_items (return_id, order_item_id, product_id, quantity) VALUES (?, ?, ?, ?)`
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		item.ReturnID = ret.ID
+		result, err := r.db.ExecContext(ctx, itemQuery, item.ReturnID, item.OrderItemID, item.ProductID, item.Quantity)
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		item.ID = uint(id)
+	}
+	return nil
+}
+
+func (r *returnRepository) GetByID(ctx context.Context, id uint) (*domain.Return, error) {
+	query := `SELECT ` + returnColumns + ` FROM order_returns WHERE id = ?`
+	ret, err := scanReturn(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	if err := r.loadItems(ctx, []*domain.Return{ret}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *returnRepository) List(ctx context.Context, filter domain.ReturnFilter) ([]*domain.Return, error) {
+	var where []string
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.OrderID != 0 {
+		where = append(where, "order_id = ?")
+		args = append(args, filter.OrderID)
+	}
+
+	query := `SELECT ` + returnColumns + ` FROM order_returns`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	var returns []*domain.Return
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		returns = append(returns, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	rows.Close()
+
+	if err := r.loadItems(ctx, returns); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// loadItems fills in the items of returns with a single query.
+func (r *returnRepository) loadItems(ctx context.Context, returns []*domain.Return) error {
+	if len(returns) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*domain.Return, len(returns))
+	args := make([]interface{}, 0, len(returns))
+	for _, ret := range returns {
+		byID[ret.ID] = ret
+		args = append(args, ret.ID)
+	}
+	query := `SELECT id, return_id, order_item_id, product_id, quantity FROM order_return_items
+	          WHERE return_id IN (?` + strings.Repeat(", ?", len(returns)-1) + `) ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.ReturnItem
+		if err := rows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.ProductID, &item.Quantity); err != nil {
+			return r.d.TranslateError(err)
+		}
+		ret := byID[item.ReturnID]
+		ret.Items = append(ret.Items, item)
+	}
+	return r.d.TranslateError(rows.Err())
+}
+
+func (r *returnRepository) Update(ctx context.Context, ret *domain.Return) error {
+	query := `UPDATE order_returns SET note = ?, restocked = ?, refund_id = ?, updated_at = ? WHERE id = ?`
+
+	ret.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, ret.Note, ret.Restocked, ret.RefundID, r.d.Time(ret.UpdatedAt), ret.ID)
+	return r.d.TranslateError(err)
+}
+
+func (r *returnRepository) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	query := `UPDATE order_returns SET status = ?, updated_at = ? WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, to, r.d.Time(time.Now()), id, from)
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, r.d.TranslateError(err)
+	}
+	return n, nil
+}
+
+func (r *returnRepository) ReturnedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	returned := make(map[uint]int)
+	if len(statuses) == 0 {
+		return returned, nil
+	}
+
+	args := []interface{}{orderID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	query := `SELECT ri.order_item_id, SUM(ri.quantity) FROM order_return_items ri
+	          JOIN order_returns r ON r.id = ri.return_id
+	          WHERE r.order_id = ? AND r.status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)
+	          GROUP BY ri.order_item_id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderItemID uint
+		var quantity int
+		if err := rows.Scan(&orderItemID, &quantity); err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		returned[orderItemID] = quantity
+	}
+	return returned, r.d.TranslateError(rows.Err())
+}
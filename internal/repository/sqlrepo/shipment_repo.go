@@ -0,0 +1,196 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"strings"
+	"time"
+)
+
+type shipmentRepository struct {
+	db DBTX
+	d  Dialect
+}
+
+func NewShipmentRepository(db DBTX, d Dialect) repository.ShipmentRepository {
+	return &shipmentRepository{db: db, d: d}
+}
+
+const shipmentColumns = `id, order_id, carrier, tracking_number, status, shipped_at, delivered_at, created_at, updated_at`
+
+func scanShipment(row interface {
+	Scan(dest ...interface{}) error
+}) (*domain.Shipment, error) {
+	shipment := &domain.Shipment{}
+	var shippedAt, deliveredAt sql.NullTime
+	err := row.Scan(
+		&shipment.ID, &shipment.OrderID, &shipment.Carrier, &shipment.TrackingNumber, &shipment.Status,
+		&shippedAt, &deliveredAt, &shipment.CreatedAt, &shipment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if shippedAt.Valid {
+		shipment.ShippedAt = &shippedAt.Time
+	}
+	if deliveredAt.Valid {
+		shipment.DeliveredAt = &deliveredAt.Time
+	}
+	return shipment, nil
+}
+
+func (r *shipmentRepository) Create(ctx context.Context, shipment *domain.Shipment) error {
+	query := `INSERT INTO shipments (order_id, carrier, tracking_number, status, shipped_at, delivered_at, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query,
+		shipment.OrderID, shipment.Carrier, shipment.TrackingNumber, shipment.Status,
+		r.d.nullTime(shipment.ShippedAt), r.d.nullTime(shipment.DeliveredAt), r.d.Time(now), r.d.Time(now),
+	)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	shipment.ID = uint(id)
+	shipment.CreatedAt = now
+	shipment.UpdatedAt = now
+
+	itemQuery := `INSERT INTO shipment_items (shipment_id, order_item_id, product_id, quantity) VALUES (?, ?, ?, ?)`
+	for i := range shipment.Items {
+		item := &shipment.Items[i]
+		item.ShipmentID = shipment.ID
+		result, err := r.db.ExecContext(ctx, itemQuery, item.ShipmentID, item.OrderItemID, item.ProductID, item.Quantity)
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return r.d.TranslateError(err)
+		}
+		item.ID = uint(id)
+	}
+	return nil
+}
+
+func (r *shipmentRepository) GetByID(ctx context.Context, id uint) (*domain.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE id = ?`
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+
+	if err := r.loadItems(ctx, []*domain.Shipment{shipment}); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+func (r *shipmentRepository) GetByOrderID(ctx context.Context, orderID uint) ([]*domain.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE order_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	var shipments []*domain.Shipment
+	for rows.Next() {
+		shipment, err := scanShipment(rows)
+		if err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		shipments = append(shipments, shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	rows.Close()
+
+	if err := r.loadItems(ctx, shipments); err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+// loadItems fills in the items of shipments with a single query.
+func (r *shipmentRepository) loadItems(ctx context.Context, shipments []*domain.Shipment) error {
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*domain.Shipment, len(shipments))
+	args := make([]interface{}, 0, len(shipments))
+	for _, shipment := range shipments {
+		byID[shipment.ID] = shipment
+		args = append(args, shipment.ID)
+	}
+	query := `SELECT id, shipment_id, order_item_id, product_id, quantity FROM shipment_items
+	          WHERE shipment_id IN (?` + strings.Repeat(", ?", len(shipments)-1) + `) ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.ShipmentItem
+		if err := rows.Scan(&item.ID, &item.ShipmentID, &item.OrderItemID, &item.ProductID, &item.Quantity); err != nil {
+			return r.d.TranslateError(err)
+		}
+		shipment := byID[item.ShipmentID]
+		shipment.Items = append(shipment.Items, item)
+	}
+	return r.d.TranslateError(rows.Err())
+}
+
+func (r *shipmentRepository) Update(ctx context.Context, shipment *domain.Shipment) error {
+	query := `UPDATE shipments SET carrier = ?, tracking_number = ?, status = ?, shipped_at = ?, delivered_at = ?, updated_at = ?
+	          WHERE id = ?`
+
+	shipment.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query,
+		shipment.Carrier, shipment.TrackingNumber, shipment.Status,
+		r.d.nullTime(shipment.ShippedAt), r.d.nullTime(shipment.DeliveredAt), r.d.Time(shipment.UpdatedAt), shipment.ID,
+	)
+	return r.d.TranslateError(err)
+}
+
+func (r *shipmentRepository) ShippedQuantities(ctx context.Context, orderID uint, statuses ...string) (map[uint]int, error) {
+	shipped := make(map[uint]int)
+	if len(statuses) == 0 {
+		return shipped, nil
+	}
+
+	args := []interface{}{orderID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	query := `SELECT si.order_item_id, SUM(si.quantity) FROM shipment_items si
+	          JOIN shipments s ON s.id = si.shipment_id
+	          WHERE s.order_id = ? AND s.status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)
+	          GROUP BY si.order_item_id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.d.TranslateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderItemID uint
+		var quantity int
+		if err := rows.Scan(&orderItemID, &quantity); err != nil {
+			return nil, r.d.TranslateError(err)
+		}
+		shipped[orderItemID] = quantity
+	}
+	return shipped, r.d.TranslateError(rows.Err())
+}
@@ -0,0 +1,45 @@
+// Package sqlrepo holds the repositories whose SQL is the same on MySQL and
+// SQLite. What differs between the two databases is kept in a Dialect, which
+// the mysql and sqlite packages pass in when they build their repositories.
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories rely on, so the
+// same repository code runs inside and outside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Dialect is what sets one database apart from the other.
+type Dialect struct {
+	// Time turns t into the argument bound for a time column. Every time is
+	// bound through it, so that the comparisons of time columns in the
+	// queries order instants the same way on both databases.
+	Time func(t time.Time) interface{}
+	// TranslateError turns driver errors into domain errors.
+	TranslateError func(err error) error
+}
+
+// nullTime binds t through Time, or NULL when it is nil.
+func (d Dialect) nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return d.Time(*t)
+}
+
+// NullID converts a nullable ID column.
+func NullID(id sql.NullInt64) *uint {
+	if !id.Valid {
+		return nil
+	}
+	v := uint(id.Int64)
+	return &v
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+)
+
+// AddressUsecase manages customers' address books. A customer has at most
+// one default shipping and one default billing address; orders placed
+// without an address use them.
+type AddressUsecase struct {
+	addressRepo repository.AddressRepository
+	uow         repository.UnitOfWork
+}
+
+func NewAddressUsecase(addressRepo repository.AddressRepository, uow repository.UnitOfWork) *AddressUsecase {
+	return &AddressUsecase{
+		addressRepo: addressRepo,
+		uow:         uow,
+	}
+}
+
+// CreateAddress adds an address to the address book of userID. The first
+// address becomes the default of both kinds.
+func (u *AddressUsecase) CreateAddress(ctx context.Context, userID uint, req *domain.AddressRequest) (*domain.Address, error) {
+	address := &domain.Address{
+		UserID:          userID,
+		Label:           req.Label,
+		PostalAddress:   req.PostalAddress(),
+		DefaultShipping: req.DefaultShipping,
+		DefaultBilling:  req.DefaultBilling,
+	}
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		existing, err := repos.Addresses.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			address.DefaultShipping = true
+			address.DefaultBilling = true
+		}
+
+		if err := clearDefaults(ctx, repos, address); err != nil {
+			return err
+		}
+		return repos.Addresses.Create(ctx, address)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// GetAddresses lists the address book of userID, newest first.
+func (u *AddressUsecase) GetAddresses(ctx context.Context, userID uint) ([]*domain.Address, error) {
+	return u.addressRepo.GetByUserID(ctx, userID)
+}
+
+func (u *AddressUsecase) GetAddress(ctx context.Context, principal domain.Principal, id uint) (*domain.Address, error) {
+	address, err := u.addressRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "address")
+	}
+	if err := authorize(principal, address.UserID, "address"); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// UpdateAddress replaces an address. Orders keep the copy taken when they
+// were placed.
+func (u *AddressUsecase) UpdateAddress(ctx context.Context, principal domain.Principal, id uint, req *domain.AddressRequest) (*domain.Address, error) {
+	var address *domain.Address
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		var err error
+		address, err = repos.Addresses.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "address")
+		}
+		if err := authorize(principal, address.UserID, "address"); err != nil {
+			return err
+		}
+
+		address.Label = req.Label
+		address.PostalAddress = req.PostalAddress()
+		address.DefaultShipping = req.DefaultShipping
+		address.DefaultBilling = req.DefaultBilling
+		if err := clearDefaults(ctx, repos, address); err != nil {
+			return err
+		}
+		return repos.Addresses.Update(ctx, address)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// DeleteAddress removes an address. When it was a default, the newest
+// remaining address takes over.
+func (u *AddressUsecase) DeleteAddress(ctx context.Context, principal domain.Principal, id uint) error {
+	return u.uow.Do(ctx, func(repos repository.Repositories) error {
+		address, err := repos.Addresses.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "address")
+		}
+		if err := authorize(principal, address.UserID, "address"); err != nil {
+			return err
+		}
+		if err := repos.Addresses.Delete(ctx, id); err != nil {
+			return err
+		}
+		if !address.DefaultShipping && !address.DefaultBilling {
+			return nil
+		}
+
+		remaining, err := repos.Addresses.GetByUserID(ctx, address.UserID)
+		if err != nil || len(remaining) == 0 {
+			return err
+		}
+		next := remaining[0]
+		next.DefaultShipping = next.DefaultShipping || address.DefaultShipping
+		next.DefaultBilling = next.DefaultBilling || address.DefaultBilling
+		return repos.Addresses.Update(ctx, next)
+	})
+}
+
+// clearDefaults unsets the current defaults of the kinds address is about to
+// become the default of.
+func clearDefaults(ctx context.Context, repos repository.Repositories, address *domain.Address) error {
+	if address.DefaultShipping {
+		if err := repos.Addresses.ClearDefault(ctx, address.UserID, domain.AddressKindShipping); err != nil {
+			return err
+		}
+	}
+	if address.DefaultBilling {
+		if err := repos.Addresses.ClearDefault(ctx, address.UserID, domain.AddressKindBilling); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOrderAddresses picks the addresses of an order placed by userID. An
+// address given by ID must belong to the customer; otherwise the customer's
+// default is used, and billing falls back to shipping. Either may be nil
+// when the customer has no address at all.
+func resolveOrderAddresses(ctx context.Context, repos repository.Repositories, userID uint, shippingID, billingID *uint) (shipping, billing *domain.Address, err error) {
+	if shippingID != nil {
+		if shipping, err = ownAddress(ctx, repos, userID, *shippingID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if billingID != nil {
+		if billing, err = ownAddress(ctx, repos, userID, *billingID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if shipping != nil && billing != nil {
+		return shipping, billing, nil
+	}
+
+	book, err := repos.Addresses.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, address := range book {
+		if shipping == nil && address.DefaultShipping {
+			shipping = address
+		}
+		if billing == nil && address.DefaultBilling {
+			billing = address
+		}
+	}
+	if billing == nil {
+		billing = shipping
+	}
+	return shipping, billing, nil
+}
+
+func ownAddress(ctx context.Context, repos repository.Repositories, userID, id uint) (*domain.Address, error) {
+	address, err := repos.Addresses.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "address")
+	}
+	if address.UserID != userID {
+		return nil, domain.NotFound("address")
+	}
+	return address, nil
+}
+
+// snapshotOrderAddresses copies shipping and billing into order, so that
+// later edits of the address book leave the order as it was placed.
+func snapshotOrderAddresses(ctx context.Context, repos repository.Repositories, order *domain.Order, shipping, billing *domain.Address) error {
+	for _, a := range []struct {
+		kind    string
+		address *domain.Address
+		dst     **domain.OrderAddress
+	}{
+		{domain.AddressKindShipping, shipping, &order.ShippingAddress},
+		{domain.AddressKindBilling, billing, &order.BillingAddress},
+	} {
+		if a.address == nil {
+			continue
+		}
+		addressID := a.address.ID
+		snapshot := &domain.OrderAddress{
+			OrderID:       order.ID,
+			Kind:          a.kind,
+			AddressID:     &addressID,
+			PostalAddress: a.address.PostalAddress,
+		}
+		if err := repos.Orders.AddAddress(ctx, snapshot); err != nil {
+			return err
+		}
+		*a.dst = snapshot
+	}
+	return nil
+}
+
+// loadOrderAddresses fills in the address copies of order.
+func loadOrderAddresses(ctx context.Context, orderRepo repository.OrderRepository, order *domain.Order) error {
+	addresses, err := orderRepo.GetAddresses(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range addresses {
+		switch addresses[i].Kind {
+		case domain.AddressKindShipping:
+			order.ShippingAddress = &addresses[i]
+		case domain.AddressKindBilling:
+			order.BillingAddress = &addresses[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+)
+
+func TestOrderKeepsAddressSnapshot(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, owner, addresses, orders := f.ctx, f.owner, f.addresses, f.orders
+			other := f.newUser("other", domain.RoleUser)
+
+			req := &domain.AddressRequest{FullName: "Buyer", Line1: "1 Old Street", City: "Berlin", PostalCode: "10115", Country: "de"}
+			home, err := addresses.CreateAddress(ctx, f.buyer.ID, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !home.DefaultShipping || !home.DefaultBilling || home.Country != "DE" {
+				t.Errorf("first address = %+v, want the default of both kinds in DE", home)
+			}
+			foreign, err := addresses.CreateAddress(ctx, other.ID, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			items := []domain.OrderItemRequest{{ProductID: f.product.ID, Quantity: 1}}
+			if _, err := orders.CreateOrder(ctx, f.buyer.ID, &domain.OrderRequest{Items: items, ShippingAddressID: &foreign.ID}); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("order to another customer's address: got %v, want ErrNotFound", err)
+			}
+			order := f.placeOrder(1)
+
+			req.Line1 = "2 New Street"
+			if _, err := addresses.UpdateAddress(ctx, owner, home.ID, req); err != nil {
+				t.Fatal(err)
+			}
+			got, err := orders.GetOrder(ctx, owner, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ShippingAddress == nil || got.ShippingAddress.Line1 != "1 Old Street" {
+				t.Errorf("shipping address = %+v, want the copy taken at order time", got.ShippingAddress)
+			}
+			if got.BillingAddress == nil || got.BillingAddress.Line1 != "1 Old Street" {
+				t.Errorf("billing address = %+v, want the default address", got.BillingAddress)
+			}
+
+			if err := addresses.DeleteAddress(ctx, owner, home.ID); err != nil {
+				t.Fatal(err)
+			}
+			got, err = orders.GetOrder(ctx, owner, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ShippingAddress == nil || got.ShippingAddress.AddressID != nil {
+				t.Errorf("shipping address = %+v, want the copy without its deleted source", got.ShippingAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+)
+
+func TestCartLeavesStockHeldByPendingPayments(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, carts := f.ctx, f.carts
+
+			// The buyer's pending payment holds 4 of the 5 widgets, leaving 1 for
+			// everyone else.
+			f.fillCart(4)
+			if _, err := f.payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"}); err != nil {
+				t.Fatalf("InitiatePayment: %v", err)
+			}
+			other := f.newUser("other", domain.RoleUser)
+			principal := domain.Principal{UserID: other.ID, Role: domain.RoleUser}
+
+			add := func(quantity int) error {
+				_, err := carts.CreateCart(ctx, principal, other.ID, domain.RequestCart{
+					Items: []domain.CartItems{{ProductId: f.product.ID, Quantity: quantity}},
+				})
+				return err
+			}
+			if err := add(2); !errors.Is(err, domain.ErrInsufficientStock) {
+				t.Errorf("adding 2 of the 1 left: got %v, want ErrInsufficientStock", err)
+			}
+			if err := add(1); err != nil {
+				t.Fatalf("CreateCart: %v", err)
+			}
+			if err := add(1); !errors.Is(err, domain.ErrInsufficientStock) {
+				t.Errorf("adding to the cart past the 1 left: got %v, want ErrInsufficientStock", err)
+			}
+			if _, err := carts.UpdateCartItem(ctx, principal, other.ID, f.product.ID, 2); !errors.Is(err, domain.ErrInsufficientStock) {
+				t.Errorf("updating to 2 of the 1 left: got %v, want ErrInsufficientStock", err)
+			}
+		})
+	}
+}
+
+func TestUpsertCartItemMergesOrReplaces(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, carts := f.ctx, f.repos.Carts
+
+			quantity := func() int {
+				t.Helper()
+				item, err := carts.GetCartItemByUserAndProduct(ctx, f.buyer.ID, f.product.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return item.Quantity
+			}
+			upsert := func(n int, replace bool) {
+				t.Helper()
+				item := domain.CartItems{ProductId: f.product.ID, Quantity: n, Fee: f.product.Price}
+				if err := carts.UpsertCartItem(ctx, f.buyer.ID, item, replace); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			upsert(2, false)
+			if got := quantity(); got != 2 {
+				t.Errorf("new item quantity = %d, want 2", got)
+			}
+			upsert(1, false)
+			if got := quantity(); got != 3 {
+				t.Errorf("merged quantity = %d, want 3", got)
+			}
+			upsert(1, true)
+			if got := quantity(); got != 1 {
+				t.Errorf("replaced quantity = %d, want 1", got)
+			}
+			if items, err := carts.GetByUserID(ctx, f.buyer.ID); err != nil || len(items) != 1 {
+				t.Errorf("cart = %v (err %v), want one line", items, err)
+			}
+
+			// CreateCart checks stock against what the cart will hold.
+			add := func(n int, replace bool) error {
+				_, err := f.carts.CreateCart(ctx, f.owner, f.buyer.ID, domain.RequestCart{
+					Items:   []domain.CartItems{{ProductId: f.product.ID, Quantity: n}},
+					Replace: replace,
+				})
+				return err
+			}
+			if err := add(5, false); !errors.Is(err, domain.ErrInsufficientStock) {
+				t.Errorf("adding 5 to the 1 in the cart: got %v, want ErrInsufficientStock", err)
+			}
+			if err := add(5, true); err != nil {
+				t.Errorf("replacing with 5: %v", err)
+			}
+			if got := quantity(); got != 5 {
+				t.Errorf("quantity after replacing = %d, want 5", got)
+			}
+		})
+	}
+}
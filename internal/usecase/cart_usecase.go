@@ -1,8 +1,8 @@
 package usecase
 
 import (
+	"context"
 	"errors"
-	"log"
 	"shop/internal/domain"
 	"shop/internal/repository"
 	"time"
@@ -13,67 +13,248 @@ type CartUsecase struct {
 	cartRepo    repository.CartItemsRepository
 	orderRepo   repository.OrderRepository
 	productRepo repository.ProductRepository
+	uow         repository.UnitOfWork
 }
 
-func NewCartUseCase(cartRepo repository.CartItemsRepository, orderRepo repository.OrderRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository) *CartUsecase {
-	return &CartUsecase{cartRepo: cartRepo, orderRepo: orderRepo, productRepo: productRepo, userRepo: userRepo}
+func NewCartUseCase(cartRepo repository.CartItemsRepository, orderRepo repository.OrderRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository, uow repository.UnitOfWork) *CartUsecase {
+	return &CartUsecase{cartRepo: cartRepo, orderRepo: orderRepo, productRepo: productRepo, userRepo: userRepo, uow: uow}
 }
 
-func (u *CartUsecase) CreateCart(userID uint, req domain.RequestCart) error {
-	oldCart, err := u.userRepo.GetByID(userID)
-	if err != nil {
-		log.Println(err)
+// CreateCart adds items to the cart of userID. Products already in the cart
+// have their quantity increased, or replaced when req.Replace is set.
+func (u *CartUsecase) CreateCart(ctx context.Context, principal domain.Principal, userID uint, req domain.RequestCart) (*domain.CartResponse, error) {
+	if err := authorize(principal, userID, "cart"); err != nil {
+		return nil, err
+	}
+
+	// Fold repeated products into one line so they are checked and stored once
+	var order []uint
+	quantities := make(map[uint]int)
+	for _, item := range req.Items {
+		if item.Quantity < 1 {
+			return nil, domain.NewError(domain.ErrInvalidInput, "quantity must be at least 1").
+				WithDetail("product_id", item.ProductId)
+		}
+		if _, ok := quantities[item.ProductId]; !ok {
+			order = append(order, item.ProductId)
+		}
+		quantities[item.ProductId] += item.Quantity
+	}
+	if len(order) == 0 {
+		return nil, domain.NewError(domain.ErrInvalidInput, "no items to add")
+	}
+
+	var cart *domain.CartResponse
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		user, err := unlockedCartOwner(ctx, repos, userID)
+		if err != nil {
+			return err
+		}
+
+		// Units held by pending payments cannot go into another cart
+		held, err := repos.Reservations.HeldQuantities(ctx, order)
+		if err != nil {
+			return err
+		}
+
+		for _, productID := range order {
+			product, err := repos.Products.GetByID(ctx, productID)
+			if err != nil {
+				return notFound(err, "product")
+			}
+
+			// Stock has to cover what the cart will hold after the upsert
+			quantity := quantities[productID]
+			if !req.Replace {
+				existing, err := repos.Carts.GetCartItemByUserAndProduct(ctx, userID, productID)
+				if err == nil {
+					quantity += existing.Quantity
+				} else if !errors.Is(err, domain.ErrNotFound) {
+					return err
+				}
+			}
+			if available := product.Stock - held[product.ID]; available < quantity {
+				return domain.InsufficientStock(product.ID, quantity, max(available, 0))
+			}
+
+			item := domain.CartItems{
+				ProductId: productID,
+				Quantity:  quantities[productID],
+				Fee:       product.Price,
+			}
+			if err := repos.Carts.UpsertCartItem(ctx, userID, item, req.Replace); err != nil {
+				return err
+			}
+		}
+
+		cart, err = refreshCartTotal(ctx, repos, user)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if oldCart.LockCart {
-		return errors.New("You have Cart in payment processing")
+	return cart, nil
+}
+
+// GetCart returns the cart of userID priced from current product data.
+func (u *CartUsecase) GetCart(ctx context.Context, principal domain.Principal, userID uint) (*domain.CartResponse, error) {
+	if err := authorize(principal, userID, "cart"); err != nil {
+		return nil, err
 	}
 
-	// Validate products and calculate total
-	for _, item := range req.Items {
-		product, err := u.productRepo.GetByID(item.ProductId)
+	if _, err := u.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, notFound(err, "user")
+	}
+	items, err := u.cartRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return priceCart(ctx, u.productRepo, items)
+}
+
+// UpdateCartItem sets the quantity of a product already in the cart.
+func (u *CartUsecase) UpdateCartItem(ctx context.Context, principal domain.Principal, userID, productID uint, quantity int) (*domain.CartResponse, error) {
+	if err := authorize(principal, userID, "cart"); err != nil {
+		return nil, err
+	}
+
+	var cart *domain.CartResponse
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		user, err := unlockedCartOwner(ctx, repos, userID)
+		if err != nil {
+			return err
+		}
+
+		item, err := repos.Carts.GetCartItemByUserAndProduct(ctx, userID, productID)
+		if err != nil {
+			return notFound(err, "cart item")
+		}
+		product, err := repos.Products.GetByID(ctx, productID)
+		if err != nil {
+			return notFound(err, "product")
+		}
+		held, err := repos.Reservations.HeldQuantities(ctx, []uint{productID})
 		if err != nil {
-			return errors.New("product not found")
+			return err
+		}
+		if available := product.Stock - held[productID]; available < quantity {
+			return domain.InsufficientStock(product.ID, quantity, max(available, 0))
 		}
-		if product.Stock < item.Quantity {
-			return errors.New("insufficient stock")
+
+		item.Quantity = quantity
+		if err := repos.Carts.Update(ctx, item); err != nil {
+			return err
 		}
-		// TODO: Add or Delete Old product
 
-		oldCart.TotalCart += product.Price * float64(item.Quantity)
+		cart, err = refreshCartTotal(ctx, repos, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return cart, nil
+}
 
-	// Create order items and update stock
-	for _, item := range req.Items {
-		item.CreatedAt = time.Now()
-		item.UserId = oldCart.ID
-		if err = u.cartRepo.CreateCartItems(userID, item); err != nil {
+// RemoveCartItem deletes one product from the cart.
+func (u *CartUsecase) RemoveCartItem(ctx context.Context, principal domain.Principal, userID, productID uint) (*domain.CartResponse, error) {
+	if err := authorize(principal, userID, "cart"); err != nil {
+		return nil, err
+	}
+
+	var cart *domain.CartResponse
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		user, err := unlockedCartOwner(ctx, repos, userID)
+		if err != nil {
 			return err
 		}
 
-		// Update product stock
-		// product, _ := u.productRepo.GetByID(item.ProductId)
-		// newStock := product.Stock - item.Quantity
-		// if err := u.productRepo.UpdateStock(item.ProductId, newStock); err != nil {
-		// 	return err
-		// }
+		if _, err := repos.Carts.GetCartItemByUserAndProduct(ctx, userID, productID); err != nil {
+			return notFound(err, "cart item")
+		}
+		if err := repos.Carts.DeleteCartItem(ctx, userID, productID); err != nil {
+			return err
+		}
+
+		cart, err = refreshCartTotal(ctx, repos, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err = u.userRepo.Update(oldCart); err != nil {
+	return cart, nil
+}
+
+// ClearCart removes every item from the cart.
+func (u *CartUsecase) ClearCart(ctx context.Context, principal domain.Principal, userID uint) error {
+	if err := authorize(principal, userID, "cart"); err != nil {
 		return err
 	}
-	return nil
+
+	return u.uow.Do(ctx, func(repos repository.Repositories) error {
+		user, err := unlockedCartOwner(ctx, repos, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Carts.ClearCart(ctx, userID); err != nil {
+			return err
+		}
+
+		user.TotalCart = 0
+		return repos.Users.Update(ctx, user)
+	})
+}
+
+// unlockedCartOwner loads the owner of a cart and fails with ErrCartLocked
+// while the cart is being paid for.
+func unlockedCartOwner(ctx context.Context, repos repository.Repositories, userID uint) (*domain.User, error) {
+	user, err := repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+	if user.CartLocked(time.Now()) {
+		return nil, domain.NewError(domain.ErrCartLocked, "cart is locked for payment")
+	}
+	return user, nil
 }
 
-func (u *CartUsecase) GetCart(userid uint) (domain.RequestCart, error) {
-	usercart, err := u.userRepo.GetByID(userid)
+// refreshCartTotal reprices the cart of user and stores the new total.
+func refreshCartTotal(ctx context.Context, repos repository.Repositories, user *domain.User) (*domain.CartResponse, error) {
+	items, err := repos.Carts.GetByUserID(ctx, user.ID)
 	if err != nil {
-		return domain.RequestCart{}, err
+		return nil, err
 	}
-	items, err := u.cartRepo.GetByUserID(usercart.ID)
+	cart, err := priceCart(ctx, repos.Products, items)
 	if err != nil {
-		return domain.RequestCart{}, err
+		return nil, err
+	}
+
+	user.TotalCart = cart.Total
+	if err := repos.Users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// priceCart builds a CartResponse from current product prices.
+func priceCart(ctx context.Context, products repository.ProductRepository, items []domain.CartItems) (*domain.CartResponse, error) {
+	cart := &domain.CartResponse{Items: make([]domain.CartItemResponse, 0, len(items))}
+	for _, item := range items {
+		product, err := products.GetByID(ctx, item.ProductId)
+		if err != nil {
+			return nil, notFound(err, "product")
+		}
+
+		line := domain.CartItemResponse{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Quantity:    item.Quantity,
+			Price:       product.Price,
+			Total:       product.Price * float64(item.Quantity),
+		}
+		cart.Items = append(cart.Items, line)
+		cart.Total += line.Total
 	}
-	var respon domain.RequestCart
-	respon.Items = items
-	return respon, nil
+	return cart, nil
 }
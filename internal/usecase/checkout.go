@@ -0,0 +1,255 @@
+package usecase
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"strings"
+	"time"
+)
+
+// CheckoutPricing is what a checkout charges on top of the cart.
+type CheckoutPricing struct {
+	// TaxRate is charged on the discounted subtotal plus shipping.
+	TaxRate         float64
+	ShippingMethods []domain.ShippingMethod
+	Discounts       []domain.Discount
+}
+
+// CheckoutUsecase turns a customer's cart into an order awaiting payment and
+// starts the payment for it. Stock is taken when the order is placed and
+// returned when the payment fails, is cancelled or expires.
+type CheckoutUsecase struct {
+	orderRepo repository.OrderRepository
+	uow       repository.UnitOfWork
+	payments  *PaymentUsecase
+	pricing   CheckoutPricing
+}
+
+func NewCheckoutUsecase(orderRepo repository.OrderRepository, uow repository.UnitOfWork, payments *PaymentUsecase, pricing CheckoutPricing) *CheckoutUsecase {
+	return &CheckoutUsecase{
+		orderRepo: orderRepo,
+		uow:       uow,
+		payments:  payments,
+		pricing:   pricing,
+	}
+}
+
+// ShippingMethods lists the shipping methods customers can pick.
+func (u *CheckoutUsecase) ShippingMethods() []domain.ShippingMethod {
+	return u.pricing.ShippingMethods
+}
+
+// Checkout prices the cart of userID from current product prices, places the
+// order in awaiting_payment and starts paying for it. The cart stays locked
+// until the payment settles and is cleared once it completes.
+func (u *CheckoutUsecase) Checkout(ctx context.Context, userID uint, req *domain.CheckoutRequest) (*domain.Checkout, error) {
+	method, ok := u.shippingMethod(req.ShippingMethod)
+	if !ok {
+		return nil, domain.NewError(domain.ErrInvalidInput, "unknown shipping method").
+			WithDetail("shipping_method", req.ShippingMethod)
+	}
+	var discount *domain.Discount
+	if req.DiscountCode != "" {
+		if discount, ok = u.discount(req.DiscountCode); !ok {
+			return nil, domain.NewError(domain.ErrInvalidInput, "unknown discount code").
+				WithDetail("discount_code", req.DiscountCode)
+		}
+	}
+
+	checkout := &domain.Checkout{ShippingMethod: method, TaxRate: u.pricing.TaxRate}
+	var payment *domain.Payment
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		user, err := unlockedCartOwner(ctx, repos, userID)
+		if err != nil {
+			return err
+		}
+
+		cartItems, err := repos.Carts.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(cartItems) == 0 {
+			return domain.NewError(domain.ErrInvalidInput, "cart is empty")
+		}
+
+		shipping, billing, err := resolveOrderAddresses(ctx, repos, userID, req.ShippingAddressID, req.BillingAddressID)
+		if err != nil {
+			return err
+		}
+		if shipping == nil {
+			return domain.NewError(domain.ErrInvalidInput, "a shipping address is required")
+		}
+
+		if err := priceCheckoutLines(ctx, repos, checkout, cartItems); err != nil {
+			return err
+		}
+		if err := u.price(checkout, discount); err != nil {
+			return err
+		}
+
+		order := &domain.Order{
+			UserID:         userID,
+			Total:          checkout.Total,
+			Status:         domain.OrderStatusAwaitingPayment,
+			ShippingMethod: method.Code,
+			ShippingCost:   checkout.Shipping,
+			DiscountCode:   checkout.DiscountCode,
+			Discount:       checkout.Discount,
+			Tax:            checkout.Tax,
+		}
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			return err
+		}
+		if err := recordOrderCreated(ctx, repos, order, domain.Principal{UserID: userID}, "order placed at checkout"); err != nil {
+			return err
+		}
+		for _, line := range checkout.Items {
+			item := domain.OrderItem{
+				OrderID:   order.ID,
+				ProductID: line.ProductID,
+				Quantity:  line.Quantity,
+				Price:     line.Price,
+			}
+			if err := repos.Orders.CreateOrderItem(ctx, &item); err != nil {
+				return err
+			}
+			if err := decrementStock(ctx, repos, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		if err := snapshotOrderAddresses(ctx, repos, order, shipping, billing); err != nil {
+			return err
+		}
+
+		payment = &domain.Payment{
+			UserID:            userID,
+			OrderID:           &order.ID,
+			ShippingAddressID: &shipping.ID,
+			BillingAddressID:  &billing.ID,
+			Amount:            checkout.Total,
+			Status:            domain.PaymentStatusPending,
+			PaymentMethod:     req.PaymentMethod,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		if err := repos.Payments.Create(ctx, payment); err != nil {
+			return err
+		}
+		if err := u.payments.lockCart(ctx, repos, payment); err != nil {
+			return err
+		}
+
+		user.TotalCart = checkout.Subtotal
+		return repos.Users.Update(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed charge cancels the order and returns its stock.
+	if err := u.payments.charge(ctx, payment); err != nil {
+		return nil, err
+	}
+	checkout.Payment = payment
+
+	checkout.Order, err = u.orderRepo.GetByID(ctx, *payment.OrderID)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if err := loadOrderItems(ctx, u.orderRepo, checkout.Order); err != nil {
+		return nil, err
+	}
+	if err := loadOrderAddresses(ctx, u.orderRepo, checkout.Order); err != nil {
+		return nil, err
+	}
+	return checkout, nil
+}
+
+// priceCheckoutLines prices cartItems from current product prices into the
+// lines and subtotal of checkout. Units held for pending payments are not
+// for sale.
+func priceCheckoutLines(ctx context.Context, repos repository.Repositories, checkout *domain.Checkout, cartItems []domain.CartItems) error {
+	ids := make([]uint, len(cartItems))
+	for i, item := range cartItems {
+		ids[i] = item.ProductId
+	}
+	held, err := repos.Reservations.HeldQuantities(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	checkout.Items = make([]domain.CheckoutLine, 0, len(cartItems))
+	for _, item := range cartItems {
+		product, err := repos.Products.GetByID(ctx, item.ProductId)
+		if err != nil {
+			return notFound(err, "product")
+		}
+		if available := product.Stock - held[product.ID]; available < item.Quantity {
+			return domain.InsufficientStock(product.ID, item.Quantity, max(available, 0))
+		}
+
+		line := domain.CheckoutLine{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Quantity:    item.Quantity,
+			Price:       product.Price,
+			CartPrice:   item.Fee,
+			Total:       roundCents(product.Price * float64(item.Quantity)),
+		}
+		if line.CartPrice != line.Price {
+			checkout.Repriced = true
+		}
+		checkout.Items = append(checkout.Items, line)
+		checkout.Subtotal += line.Total
+	}
+	checkout.Subtotal = roundCents(checkout.Subtotal)
+	return nil
+}
+
+// price applies discount, when there is one, shipping and tax to the
+// subtotal of checkout.
+func (u *CheckoutUsecase) price(checkout *domain.Checkout, discount *domain.Discount) error {
+	if discount != nil {
+		if checkout.Subtotal < discount.MinSubtotal {
+			return domain.NewError(domain.ErrInvalidInput, "subtotal is below the minimum for this discount code").
+				WithDetail("discount_code", discount.Code).
+				WithDetail("min_subtotal", discount.MinSubtotal)
+		}
+		checkout.DiscountCode = discount.Code
+		if discount.Percent > 0 {
+			checkout.Discount = roundCents(checkout.Subtotal * discount.Percent / 100)
+		} else {
+			checkout.Discount = min(discount.Amount, checkout.Subtotal)
+		}
+	}
+
+	discounted := checkout.Subtotal - checkout.Discount
+	method := checkout.ShippingMethod
+	if method.FreeOver <= 0 || discounted < method.FreeOver {
+		checkout.Shipping = method.Price
+	}
+
+	checkout.Tax = roundCents((discounted + checkout.Shipping) * u.pricing.TaxRate)
+	checkout.Total = roundCents(discounted + checkout.Shipping + checkout.Tax)
+	return nil
+}
+
+func (u *CheckoutUsecase) shippingMethod(code string) (domain.ShippingMethod, bool) {
+	for _, method := range u.pricing.ShippingMethods {
+		if method.Code == code {
+			return method, true
+		}
+	}
+	return domain.ShippingMethod{}, false
+}
+
+// discount looks up a discount code regardless of case.
+func (u *CheckoutUsecase) discount(code string) (*domain.Discount, bool) {
+	for i := range u.pricing.Discounts {
+		if strings.EqualFold(u.pricing.Discounts[i].Code, code) {
+			return &u.pricing.Discounts[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,170 @@
+package usecase_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"shop/internal/domain"
+	"shop/internal/gateway/simulator"
+)
+
+func TestCheckoutPricesCartAndSettlesOrder(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, repos := f.ctx, f.repos
+			f.addAddress()
+
+			// The price went up since the widgets were put in the cart.
+			f.fillCart(2)
+			f.product.Price = 12
+			if err := repos.Products.Update(ctx, f.product); err != nil {
+				t.Fatal(err)
+			}
+			req := &domain.CheckoutRequest{PaymentMethod: "card", ShippingMethod: "standard", DiscountCode: "ten"}
+
+			checkout, err := f.checkouts.Checkout(ctx, f.buyer.ID, req)
+			if err != nil {
+				t.Fatalf("Checkout: %v", err)
+			}
+			// 24 less 2.40 discount, plus 5 shipping and 10% tax on 26.60.
+			if !checkout.Repriced || checkout.Subtotal != 24 || checkout.Discount != 2.4 || checkout.Tax != 2.66 || checkout.Total != 29.26 {
+				t.Errorf("checkout = %+v, want a repriced subtotal of 24 and a total of 29.26", checkout)
+			}
+			if checkout.Order.Status != domain.OrderStatusAwaitingPayment || checkout.Order.ShippingAddress == nil {
+				t.Errorf("order = %+v, want it awaiting payment with a shipping address", checkout.Order)
+			}
+			if checkout.Payment.Amount != checkout.Total {
+				t.Errorf("payment amount = %v, want %v", checkout.Payment.Amount, checkout.Total)
+			}
+			if got := f.stock(); got != 3 {
+				t.Errorf("stock after checkout = %d, want 3", got)
+			}
+
+			err = f.payments.ProcessPayment(ctx, checkout.Payment.ID, domain.PaymentGatewayResponse{Success: true, TransactionID: checkout.Payment.GatewayTransactionID})
+			if err != nil {
+				t.Fatalf("ProcessPayment: %v", err)
+			}
+			paid, err := f.orders.GetOrder(ctx, f.owner, checkout.Order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if paid.Status != domain.OrderStatusPaid || paid.Tax != 2.66 || paid.ShippingMethod != "standard" {
+				t.Errorf("order = %+v, want it paid with its checkout pricing", paid)
+			}
+			if items, err := repos.Carts.GetByUserID(ctx, f.buyer.ID); err != nil || len(items) != 0 {
+				t.Errorf("cart = %v, %v; want it cleared", items, err)
+			}
+
+			f.fillCart(2)
+			checkout, err = f.checkouts.Checkout(ctx, f.buyer.ID, req)
+			if err != nil {
+				t.Fatalf("second Checkout: %v", err)
+			}
+			if _, err := f.orders.CancelOrder(ctx, f.owner, checkout.Order.ID, ""); err != nil {
+				t.Fatalf("CancelOrder: %v", err)
+			}
+			payment, err := repos.Payments.GetByID(ctx, checkout.Payment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payment.Status != domain.PaymentStatusCancelled {
+				t.Errorf("payment status = %q, want %q", payment.Status, domain.PaymentStatusCancelled)
+			}
+			if got := f.stock(); got != 3 {
+				t.Errorf("stock after cancelling = %d, want 3", got)
+			}
+			if buyer, err := repos.Users.GetByID(ctx, f.buyer.ID); err != nil || buyer.CartLocked(time.Now()) {
+				t.Errorf("cart still locked after cancelling the order (err %v)", err)
+			}
+		})
+	}
+}
+
+func TestRefundsOfDiscountedCheckoutAddUpToTheCharge(t *testing.T) {
+	tests := []struct {
+		name, code string
+		// total is what checking out two widgets charges, unit what
+		// refunding one of them gives back.
+		total, unit float64
+		// partial refunds one widget before the order is cancelled.
+		partial bool
+	}{
+		// 20 less 10, plus 5 shipping and 10% tax on 15.
+		{name: "half off", code: "HALF", total: 16.5, unit: 8.25},
+		{name: "half off after a partial refund", code: "HALF", total: 16.5, unit: 8.25, partial: true},
+		// 20 less 2, plus 5 shipping and 10% tax on 23.
+		{name: "ten off", code: "TEN", total: 25.3, unit: 12.65},
+		{name: "ten off after a partial refund", code: "TEN", total: 25.3, unit: 12.65, partial: true},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					gw := simulator.New(simulator.Config{Outcome: simulator.OutcomeSuccess})
+					f := newFixture(t, b, gw)
+					ctx := f.ctx
+					f.addAddress()
+					f.fillCart(2)
+
+					checkout, err := f.checkouts.Checkout(ctx, f.buyer.ID, &domain.CheckoutRequest{PaymentMethod: "card", ShippingMethod: "standard", DiscountCode: tt.code})
+					if err != nil {
+						t.Fatalf("Checkout: %v", err)
+					}
+					if checkout.Total != tt.total {
+						t.Fatalf("total = %v, want %v", checkout.Total, tt.total)
+					}
+					payment := checkout.Payment
+					settled(t, gw, payment.GatewayTransactionID)
+					if err := f.payments.ProcessPayment(ctx, payment.ID, domain.PaymentGatewayResponse{Success: true, TransactionID: payment.GatewayTransactionID}); err != nil {
+						t.Fatalf("ProcessPayment: %v", err)
+					}
+
+					order, err := f.orders.GetOrder(ctx, f.owner, checkout.Order.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want := 1
+					if tt.partial {
+						refund, err := f.refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{
+							Items:   []domain.RefundItemRequest{{OrderItemID: order.Items[0].ID, Quantity: 1}},
+							Restock: true,
+						})
+						if err != nil {
+							t.Fatalf("RefundPayment: %v", err)
+						}
+						if refund.Amount != tt.unit {
+							t.Errorf("refund of one widget = %v, want %v", refund.Amount, tt.unit)
+						}
+						// Customers cannot cancel partly refunded orders.
+						if _, err := f.orders.UpdateOrderStatus(ctx, f.admin, order.ID, domain.OrderStatusCancelled, ""); err != nil {
+							t.Fatalf("UpdateOrderStatus: %v", err)
+						}
+						want++
+					} else if _, err := f.orders.CancelOrder(ctx, f.owner, order.ID, ""); err != nil {
+						t.Fatalf("CancelOrder: %v", err)
+					}
+					refunds, err := f.refunds.GetRefunds(ctx, f.admin, payment.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var refunded float64
+					for _, refund := range refunds {
+						if refund.Status != domain.RefundStatusSucceeded {
+							t.Errorf("refund %d is %s, want it succeeded", refund.ID, refund.Status)
+						}
+						refunded += refund.Amount
+					}
+					if len(refunds) != want || math.Round(refunded*100) != math.Round(tt.total*100) {
+						t.Errorf("refunded %v in %d refunds, want the %v charged in %d", refunded, len(refunds), tt.total, want)
+					}
+					if state := f.checkoutState(payment.ID); state.payment.Status != domain.PaymentStatusRefunded || state.stock != 5 {
+						t.Errorf("after cancelling: %+v, want the payment refunded and stock 5", state)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"errors"
+	"shop/internal/domain"
+)
+
+// notFound names the missing resource when err is a bare domain.ErrNotFound
+// from a repository. Any other error is returned unchanged.
+func notFound(err error, resource string) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NotFound(resource)
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shop/internal/domain"
+	"shop/internal/gateway"
+	"shop/internal/gateway/simulator"
+	"shop/internal/repository"
+	"shop/internal/repository/memory"
+	"shop/internal/repository/sqlite"
+	"shop/internal/usecase"
+	"shop/migrations"
+	"shop/pkg/config"
+	"shop/pkg/database"
+)
+
+type backend struct {
+	name string
+	open func(t *testing.T) (repository.Repositories, repository.UnitOfWork)
+}
+
+var backends = []backend{
+	{"memory", func(t *testing.T) (repository.Repositories, repository.UnitOfWork) {
+		store := memory.NewStore()
+		return store.Repositories(), memory.NewUnitOfWork(store)
+	}},
+	{"sqlite", func(t *testing.T) (repository.Repositories, repository.UnitOfWork) {
+		db, err := database.NewSQLiteConnection(config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "shop.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := database.NewMigrator(db, migrations.SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return sqlite.NewRepositories(db), sqlite.NewUnitOfWork(db)
+	}},
+}
+
+// testPricing is what checkouts in the tests charge: 10% tax, 5 for standard
+// shipping and two discount codes.
+var testPricing = usecase.CheckoutPricing{
+	TaxRate:         0.1,
+	ShippingMethods: []domain.ShippingMethod{{Code: "standard", Price: 5}},
+	Discounts:       []domain.Discount{{Code: "TEN", Percent: 10}, {Code: "HALF", Percent: 50}},
+}
+
+// fixture is a fresh store on one backend with the usecases wired as in
+// main, a widget priced 10 with 5 in stock, a buyer and a member of staff.
+type fixture struct {
+	t     *testing.T
+	ctx   context.Context
+	repos repository.Repositories
+	uow   repository.UnitOfWork
+
+	product *domain.Product
+	buyer   *domain.User
+	// owner acts as the buyer, admin as the member of staff.
+	owner, admin domain.Principal
+
+	carts     *usecase.CartUsecase
+	orders    *usecase.OrderUsecase
+	payments  *usecase.PaymentUsecase
+	refunds   *usecase.RefundUsecase
+	shipments *usecase.ShipmentUsecase
+	returns   *usecase.ReturnUsecase
+	addresses *usecase.AddressUsecase
+	checkouts *usecase.CheckoutUsecase
+}
+
+// newFixture opens b. Charges go to gw, or to a simulator that never settles
+// them when gw is nil.
+func newFixture(t *testing.T, b backend, gw gateway.PaymentGateway) *fixture {
+	t.Helper()
+	if gw == nil {
+		gw = simulator.New(simulator.Config{Outcome: simulator.OutcomeTimeout})
+	}
+
+	f := &fixture{t: t, ctx: context.Background()}
+	f.repos, f.uow = b.open(t)
+	repos, uow := f.repos, f.uow
+
+	f.product = &domain.Product{Name: "widget", Price: 10, Stock: 5}
+	if err := repos.Products.Create(f.ctx, f.product); err != nil {
+		t.Fatal(err)
+	}
+	f.buyer = f.newUser("buyer", domain.RoleUser)
+	f.owner = domain.Principal{UserID: f.buyer.ID, Role: domain.RoleUser}
+	f.admin = domain.Principal{UserID: f.newUser("staff", domain.RoleAdmin).ID, Role: domain.RoleAdmin}
+
+	reservations := usecase.NewReservationService(repos.Reservations, time.Minute)
+	f.carts = usecase.NewCartUseCase(repos.Carts, repos.Orders, repos.Products, repos.Users, uow)
+	f.payments = usecase.NewPaymentUseCase(repos.Payments, repos.Carts, repos.Orders, repos.Products, repos.Users, gw, uow, time.Minute, reservations)
+	f.refunds = usecase.NewRefundUsecase(repos.Payments, repos.Refunds, gw, uow)
+	f.orders = usecase.NewOrderUsecase(repos.Orders, repos.Products, repos.Payments, repos.Users, repos.Shipments, uow, f.refunds)
+	f.shipments = usecase.NewShipmentUsecase(repos.Orders, repos.Shipments, uow)
+	f.returns = usecase.NewReturnUsecase(repos.Orders, repos.Payments, repos.Returns, uow, f.refunds)
+	f.addresses = usecase.NewAddressUsecase(repos.Addresses, uow)
+	f.checkouts = usecase.NewCheckoutUsecase(repos.Orders, uow, f.payments, testPricing)
+	return f
+}
+
+func (f *fixture) newUser(name, role string) *domain.User {
+	f.t.Helper()
+	user := &domain.User{Username: name, Email: fmt.Sprintf("%s@example.com", name), Role: role}
+	if err := f.repos.Users.Create(f.ctx, user); err != nil {
+		f.t.Fatal(err)
+	}
+	return user
+}
+
+// fillCart puts quantity widgets in the buyer's cart at their current price.
+func (f *fixture) fillCart(quantity int) {
+	f.t.Helper()
+	item := domain.CartItems{ProductId: f.product.ID, Quantity: quantity, Fee: f.product.Price}
+	if err := f.repos.Carts.UpsertCartItem(f.ctx, f.buyer.ID, item, true); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// placeOrder orders quantity widgets for the buyer.
+func (f *fixture) placeOrder(quantity int) *domain.Order {
+	f.t.Helper()
+	order, err := f.orders.CreateOrder(f.ctx, f.buyer.ID, &domain.OrderRequest{
+		Items: []domain.OrderItemRequest{{ProductID: f.product.ID, Quantity: quantity}},
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return order
+}
+
+// addAddress gives the buyer a default shipping and billing address.
+func (f *fixture) addAddress() *domain.Address {
+	f.t.Helper()
+	address, err := f.addresses.CreateAddress(f.ctx, f.buyer.ID, &domain.AddressRequest{
+		FullName: "Buyer", Line1: "1 Main Street", City: "Berlin", PostalCode: "10115", Country: "DE",
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return address
+}
+
+func (f *fixture) stock() int {
+	f.t.Helper()
+	product, err := f.repos.Products.GetByID(f.ctx, f.product.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return product.Stock
+}
+
+// checkoutState is what a settlement leaves behind for the buyer.
+type checkoutState struct {
+	payment *domain.Payment
+	orders  int
+	stock   int
+	held    int
+	cart    int
+	locked  bool
+}
+
+func (f *fixture) checkoutState(paymentID uint) checkoutState {
+	f.t.Helper()
+	ctx, repos := f.ctx, f.repos
+
+	var state checkoutState
+	var err error
+	if state.payment, err = repos.Payments.GetByID(ctx, paymentID); err != nil {
+		f.t.Fatal(err)
+	}
+	orders, err := repos.Orders.GetByUserID(ctx, f.buyer.ID, 10, 0)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	state.orders = len(orders)
+	state.stock = f.stock()
+	held, err := repos.Reservations.HeldQuantities(ctx, []uint{f.product.ID})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	state.held = held[f.product.ID]
+	cart, err := repos.Carts.GetByUserID(ctx, f.buyer.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	state.cart = len(cart)
+	user, err := repos.Users.GetByID(ctx, f.buyer.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	state.locked = user.CartLocked(time.Now())
+	return state
+}
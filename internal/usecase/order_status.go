@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+)
+
+// actorOf describes who is behind principal in the order status history. The
+// zero principal stands for the system itself, such as payment callbacks.
+func actorOf(principal domain.Principal) (string, *uint) {
+	if principal.UserID == 0 {
+		return domain.ActorSystem, nil
+	}
+	userID := principal.UserID
+	if principal.IsAdmin() {
+		return domain.ActorAdmin, &userID
+	}
+	return domain.ActorCustomer, &userID
+}
+
+// recordOrderCreated starts the status history of a new order.
+func recordOrderCreated(ctx context.Context, repos repository.Repositories, order *domain.Order, principal domain.Principal, reason string) error {
+	actor, changedBy := actorOf(principal)
+	return repos.Orders.AddStatusHistory(ctx, &domain.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		Actor:     actor,
+		ChangedBy: changedBy,
+		Reason:    reason,
+	})
+}
+
+// transitionOrder moves order to status to and records the change, inside the
+// unit of work that repos belongs to. It fails with ErrInvalidState when the
+// lifecycle does not allow the change, and with ErrConflict when the order's
+// status changed since it was read.
+func transitionOrder(ctx context.Context, repos repository.Repositories, order *domain.Order, to string, principal domain.Principal, reason string) error {
+	from := order.Status
+	if !domain.CanTransitionOrder(from, to) {
+		return domain.NewError(domain.ErrInvalidState, "order cannot move to this status").
+			WithDetail("from", from).
+			WithDetail("to", to)
+	}
+
+	n, err := repos.Orders.TransitionStatus(ctx, order.ID, from, to)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.NewError(domain.ErrConflict, "order status was changed by another request")
+	}
+
+	actor, changedBy := actorOf(principal)
+	err = repos.Orders.AddStatusHistory(ctx, &domain.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		ChangedBy:  changedBy,
+		Reason:     reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	order.Status = to
+	return nil
+}
@@ -1,97 +1,410 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"log"
 	"shop/internal/domain"
 	"shop/internal/repository"
 )
 
 type OrderUsecase struct {
-	orderRepo   repository.OrderRepository
-	productRepo repository.ProductRepository
+	orderRepo    repository.OrderRepository
+	productRepo  repository.ProductRepository
+	paymentRepo  repository.PaymentRepository
+	userRepo     repository.UserRepository
+	shipmentRepo repository.ShipmentRepository
+	uow          repository.UnitOfWork
+	refunds      *RefundUsecase
 }
 
-func NewOrderUsecase(orderRepo repository.OrderRepository, productRepo repository.ProductRepository) *OrderUsecase {
+func NewOrderUsecase(orderRepo repository.OrderRepository, productRepo repository.ProductRepository, paymentRepo repository.PaymentRepository, userRepo repository.UserRepository, shipmentRepo repository.ShipmentRepository, uow repository.UnitOfWork, refunds *RefundUsecase) *OrderUsecase {
 	return &OrderUsecase{
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
+		orderRepo:    orderRepo,
+		productRepo:  productRepo,
+		paymentRepo:  paymentRepo,
+		userRepo:     userRepo,
+		shipmentRepo: shipmentRepo,
+		uow:          uow,
+		refunds:      refunds,
 	}
 }
 
-func (u *OrderUsecase) CreateOrder(userID uint, req *domain.OrderRequest) (*domain.Order, error) {
-	var total float64
-	var orderItems []domain.OrderItem
+// customerCancellable are the statuses in which the owner may still cancel an
+// order: nothing has been handed to fulfilment yet.
+var customerCancellable = map[string]bool{
+	domain.OrderStatusPending:         true,
+	domain.OrderStatusAwaitingPayment: true,
+	domain.OrderStatusPaid:            true,
+}
 
-	// Validate products and calculate total
-	for _, item := range req.Items {
-		product, err := u.productRepo.GetByID(item.ProductID)
+func (u *OrderUsecase) CreateOrder(ctx context.Context, userID uint, req *domain.OrderRequest) (*domain.Order, error) {
+	var order *domain.Order
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		shipping, billing, err := resolveOrderAddresses(ctx, repos, userID, req.ShippingAddressID, req.BillingAddressID)
 		if err != nil {
-			return nil, errors.New("product not found")
+			return err
+		}
+
+		var total float64
+		var orderItems []domain.OrderItem
+
+		// Validate products and calculate total
+		for _, item := range req.Items {
+			product, err := repos.Products.GetByID(ctx, item.ProductID)
+			if err != nil {
+				return notFound(err, "product")
+			}
+
+			if product.Stock < item.Quantity {
+				return domain.InsufficientStock(product.ID, item.Quantity, product.Stock)
+			}
+
+			orderItem := domain.OrderItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Price:     product.Price,
+			}
+
+			total += product.Price * float64(item.Quantity)
+			orderItems = append(orderItems, orderItem)
+		}
+
+		// Create order
+		order = &domain.Order{
+			UserID: userID,
+			Total:  total,
+			Status: domain.OrderStatusPending,
 		}
 
-		if product.Stock < item.Quantity {
-			return nil, errors.New("insufficient stock")
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			return err
+		}
+		if err := recordOrderCreated(ctx, repos, order, domain.Principal{UserID: userID}, "order placed"); err != nil {
+			return err
 		}
+		if err := snapshotOrderAddresses(ctx, repos, order, shipping, billing); err != nil {
+			return err
+		}
+
+		// Create order items and update stock
+		for _, item := range orderItems {
+			item.OrderID = order.ID
+			if err := repos.Orders.CreateOrderItem(ctx, &item); err != nil {
+				return err
+			}
 
-		orderItem := domain.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     product.Price,
+			if err := decrementStock(ctx, repos, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
 		}
 
-		total += product.Price * float64(item.Quantity)
-		orderItems = append(orderItems, orderItem)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create order
-	order := &domain.Order{
-		UserID: userID,
-		Total:  total,
-		Status: "pending",
+	return order, nil
+}
+
+func (u *OrderUsecase) GetOrder(ctx context.Context, principal domain.Principal, id uint) (*domain.Order, error) {
+	order, err := u.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if err := authorize(principal, order.UserID, "order"); err != nil {
+		return nil, err
 	}
 
-	if err := u.orderRepo.Create(order); err != nil {
+	if err := loadOrderItems(ctx, u.orderRepo, order); err != nil {
+		return nil, err
+	}
+	if err := loadOrderAddresses(ctx, u.orderRepo, order); err != nil {
 		return nil, err
 	}
 
-	// Create order items and update stock
-	for _, item := range orderItems {
-		item.OrderID = order.ID
-		if err := u.orderRepo.CreateOrderItem(&item); err != nil {
-			return nil, err
+	order.History, err = u.orderRepo.GetStatusHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Customers see who changed their order by role, not by account.
+	if !principal.IsAdmin() {
+		for i := range order.History {
+			order.History[i].ChangedBy = nil
 		}
+	}
 
-		// Update product stock
-		product, _ := u.productRepo.GetByID(item.ProductID)
-		newStock := product.Stock - item.Quantity
-		if err := u.productRepo.UpdateStock(item.ProductID, newStock); err != nil {
-			return nil, err
-		}
+	payments, err := u.paymentRepo.GetByOrderID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order.Payments = make([]domain.Payment, len(payments))
+	for i, payment := range payments {
+		order.Payments[i] = *payment
+	}
+
+	shipments, err := u.shipmentRepo.GetByOrderID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order.Shipments = make([]domain.Shipment, len(shipments))
+	for i, shipment := range shipments {
+		order.Shipments[i] = *shipment
 	}
 
 	return order, nil
 }
 
-func (u *OrderUsecase) GetOrder(id uint) (*domain.Order, error) {
-	order, err := u.orderRepo.GetByID(id)
+// loadOrderItems fills in the items of order.
+func loadOrderItems(ctx context.Context, orderRepo repository.OrderRepository, order *domain.Order) error {
+	items, err := orderRepo.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	order.Items = make([]domain.OrderItem, len(items))
+	for i, item := range items {
+		order.Items[i] = *item
+	}
+	return nil
+}
+
+// CancelOrder cancels an order that has not been handed to fulfilment yet.
+func (u *OrderUsecase) CancelOrder(ctx context.Context, principal domain.Principal, id uint, reason string) (*domain.Order, error) {
+	return u.cancel(ctx, principal, id, reason, func(status string) bool {
+		return customerCancellable[status]
+	})
+}
+
+// cancel cancels order id when allowed accepts its status, and returns the
+// units that have not been refunded yet to stock in the same transaction.
+// The payments that paid for the order are then refunded; one the gateway
+// fails to refund is marked needs_refund so that it can be settled by hand.
+func (u *OrderUsecase) cancel(ctx context.Context, principal domain.Principal, id uint, reason string, allowed func(status string) bool) (*domain.Order, error) {
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "order")
+		}
+		if err := authorize(principal, order.UserID, "order"); err != nil {
+			return err
+		}
+		if !allowed(order.Status) {
+			return domain.NewError(domain.ErrInvalidState, "order can no longer be cancelled").
+				WithDetail("status", order.Status)
+		}
+		return cancelOrder(ctx, repos, order, principal, reason)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	items, err := u.orderRepo.GetOrderItems(id)
+	// The order is cancelled; give the money back even if the request is
+	// cancelled in the meantime.
+	u.refundCancelled(context.WithoutCancel(ctx), principal, id, reason)
+
+	return u.GetOrder(ctx, principal, id)
+}
+
+// cancelOrder cancels order inside the unit of work that repos belongs to. It
+// cancels the order's shipments and pending payments, and returns the units
+// that have not been refunded yet to stock.
+func cancelOrder(ctx context.Context, repos repository.Repositories, order *domain.Order, principal domain.Principal, reason string) error {
+	if err := cancelShipments(ctx, repos, order.ID); err != nil {
+		return err
+	}
+
+	historyReason := "cancelled"
+	if reason != "" {
+		historyReason += ": " + reason
+	}
+	if err := transitionOrder(ctx, repos, order, domain.OrderStatusCancelled, principal, historyReason); err != nil {
+		return err
+	}
+
+	// Refunded units were restocked, or not, when they were refunded.
+	items, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		quantity := item.Quantity - refunded[item.ID]
+		if quantity <= 0 {
+			continue
+		}
+		n, err := repos.Products.IncrementStock(ctx, item.ProductID, quantity)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return domain.NotFound("product")
+		}
+	}
+
+	// A payment still pending for the order must not settle it any more; if
+	// the charge succeeds after all, the payment is marked needs_refund.
+	payments, err := repos.Payments.GetByOrderID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		if payment.Status != domain.PaymentStatusPending {
+			continue
+		}
+		n, err := repos.Payments.TransitionStatus(ctx, payment.ID, domain.PaymentStatusPending, domain.PaymentStatusCancelled)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return domain.NewError(domain.ErrConflict, "payment status was changed by another request")
+		}
+		if err := repos.Reservations.DeleteByPaymentID(ctx, payment.ID); err != nil {
+			return err
+		}
+		err = repos.Users.UnlockCart(ctx, payment.UserID, payment.ID)
+		if err != nil && !errors.Is(err, domain.ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateOrderStatus moves an order along its lifecycle on behalf of an admin.
+// Cancelling goes through the same restock and refund as CancelOrder, and the
+// refund statuses can only be reached by refunding.
+func (u *OrderUsecase) UpdateOrderStatus(ctx context.Context, principal domain.Principal, id uint, status, reason string) (*domain.Order, error) {
+	switch {
+	case !domain.IsOrderStatus(status):
+		return nil, domain.NewError(domain.ErrInvalidInput, "unknown order status").
+			WithDetail("status", status)
+	case status == domain.OrderStatusRefunded || status == domain.OrderStatusPartiallyRefunded:
+		return nil, domain.NewError(domain.ErrInvalidInput, "refund statuses are set by refunding the order's payments").
+			WithDetail("status", status)
+	case status == domain.OrderStatusCancelled:
+		return u.cancel(ctx, principal, id, reason, func(status string) bool {
+			return domain.CanTransitionOrder(status, domain.OrderStatusCancelled)
+		})
+	}
+
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "order")
+		}
+		if err := authorize(principal, order.UserID, "order"); err != nil {
+			return err
+		}
+		return transitionOrder(ctx, repos, order, status, principal, reason)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	order.Items = make([]domain.OrderItem, len(items))
-	for i, item := range items {
-		order.Items[i] = *item
+	return u.GetOrder(ctx, principal, id)
+}
+
+// BulkUpdateOrderStatus applies UpdateOrderStatus to each order on its own,
+// so one order that cannot move does not hold back the others. It returns
+// the error of each order, nil when it moved.
+func (u *OrderUsecase) BulkUpdateOrderStatus(ctx context.Context, principal domain.Principal, ids []uint, status, reason string) []error {
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		_, errs[i] = u.UpdateOrderStatus(ctx, principal, id, status, reason)
 	}
+	return errs
+}
 
-	return order, nil
+// ListOrders returns a page of all customers' orders and the number of
+// orders that match filter.
+func (u *OrderUsecase) ListOrders(ctx context.Context, filter domain.OrderFilter) ([]*domain.Order, int, error) {
+	if filter.Status != "" && !domain.IsOrderStatus(filter.Status) {
+		return nil, 0, domain.NewError(domain.ErrInvalidInput, "unknown order status").
+			WithDetail("status", filter.Status)
+	}
+	return u.orderRepo.List(ctx, filter)
+}
+
+// GetOrderDetail returns an order with its items, payments and history, and
+// the customer who placed it.
+func (u *OrderUsecase) GetOrderDetail(ctx context.Context, principal domain.Principal, id uint) (*domain.Order, *domain.User, error) {
+	order, err := u.GetOrder(ctx, principal, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	customer, err := u.userRepo.GetByID(ctx, order.UserID)
+	if err != nil {
+		return nil, nil, notFound(err, "user")
+	}
+	return order, customer, nil
+}
+
+// refundCancelled refunds every settled payment of cancelled order orderID.
+// The stock has already been returned, so refunds do not restock.
+func (u *OrderUsecase) refundCancelled(ctx context.Context, principal domain.Principal, orderID uint, reason string) {
+	payments, err := u.paymentRepo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		log.Printf("Error listing payments of cancelled order %d: %v", orderID, err)
+		return
+	}
+
+	refundReason := "order cancelled"
+	if reason != "" {
+		refundReason += ": " + reason
+	}
+
+	for _, payment := range payments {
+		if payment.Status != domain.PaymentStatusCompleted && payment.Status != domain.PaymentStatusPartiallyRefunded {
+			continue
+		}
+
+		_, err := u.refunds.RefundPayment(ctx, principal, payment.ID, &domain.RefundRequest{
+			Reason: refundReason,
+		})
+		if err == nil {
+			continue
+		}
+		log.Printf("Error refunding payment %d of cancelled order %d: %v", payment.ID, orderID, err)
+		if _, err := u.paymentRepo.TransitionStatus(ctx, payment.ID, payment.Status, domain.PaymentStatusNeedsRefund); err != nil {
+			log.Printf("Error marking payment %d for refund: %v", payment.ID, err)
+		}
+	}
 }
 
-func (u *OrderUsecase) GetUserOrders(userID uint, page, limit int) ([]*domain.Order, error) {
+func (u *OrderUsecase) GetUserOrders(ctx context.Context, userID uint, page, limit int) ([]*domain.Order, error) {
 	offset := (page - 1) * limit
-	return u.orderRepo.GetByUserID(userID, limit, offset)
+	return u.orderRepo.GetByUserID(ctx, userID, limit, offset)
+}
+
+// decrementStock takes quantity units of a product. The check and the update
+// are one statement, so an order that loses a race for the last units, or
+// needs units held for pending payments, fails with ErrInsufficientStock
+// instead of driving the stock negative.
+func decrementStock(ctx context.Context, repos repository.Repositories, productID uint, quantity int) error {
+	n, err := repos.Products.DecrementStock(ctx, productID, quantity)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	product, err := repos.Products.GetByID(ctx, productID)
+	if err != nil {
+		return notFound(err, "product")
+	}
+	held, err := repos.Reservations.HeldQuantities(ctx, []uint{productID})
+	if err != nil {
+		return err
+	}
+	return domain.InsufficientStock(productID, quantity, product.Stock-held[productID])
 }
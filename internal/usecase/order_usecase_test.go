@@ -0,0 +1,183 @@
+package usecase_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"shop/internal/domain"
+)
+
+func TestCreateOrderConcurrentlyNeverOversells(t *testing.T) {
+	const stock, buyers = 5, 20
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+
+			users := make([]uint, buyers)
+			for i := range users {
+				users[i] = f.newUser(fmt.Sprintf("buyer%d", i), domain.RoleUser).ID
+			}
+
+			req := &domain.OrderRequest{Items: []domain.OrderItemRequest{{ProductID: f.product.ID, Quantity: 1}}}
+
+			var wg sync.WaitGroup
+			errs := make([]error, buyers)
+			for i, userID := range users {
+				wg.Add(1)
+				go func(i int, userID uint) {
+					defer wg.Done()
+					_, errs[i] = f.orders.CreateOrder(f.ctx, userID, req)
+				}(i, userID)
+			}
+			wg.Wait()
+
+			var placed int
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					placed++
+				case !errors.Is(err, domain.ErrInsufficientStock):
+					t.Errorf("CreateOrder: unexpected error %v", err)
+				}
+			}
+			if placed != stock {
+				t.Errorf("placed %d orders, want %d", placed, stock)
+			}
+			if got := f.stock(); got != 0 {
+				t.Errorf("stock = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestDecrementStockConcurrently(t *testing.T) {
+	const workers = 50
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			if err := f.repos.Products.UpdateStock(f.ctx, f.product.ID, 10); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var taken int64
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := f.repos.Products.DecrementStock(f.ctx, f.product.ID, 1)
+					if err != nil {
+						t.Errorf("DecrementStock: %v", err)
+						return
+					}
+					mu.Lock()
+					taken += n
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if got := f.stock(); got < 0 || taken != 10 || got != 0 {
+				t.Errorf("took %d units leaving stock %d, want 10 units leaving 0", taken, got)
+			}
+		})
+	}
+}
+
+func TestCancelOrderRestocks(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			order := f.placeOrder(3)
+
+			cancelled, err := f.orders.CancelOrder(f.ctx, f.owner, order.ID, "changed my mind")
+			if err != nil {
+				t.Fatalf("CancelOrder: %v", err)
+			}
+			if cancelled.Status != domain.OrderStatusCancelled {
+				t.Errorf("status = %q, want %q", cancelled.Status, domain.OrderStatusCancelled)
+			}
+			if n := len(cancelled.History); n != 2 || cancelled.History[n-1].Reason != "cancelled: changed my mind" {
+				t.Errorf("history = %+v, want the cancellation with its reason last", cancelled.History)
+			}
+			if got := f.stock(); got != 5 {
+				t.Errorf("stock = %d, want 5", got)
+			}
+
+			if _, err := f.orders.CancelOrder(f.ctx, f.owner, order.ID, ""); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("second CancelOrder: got %v, want ErrInvalidState", err)
+			}
+		})
+	}
+}
+
+func TestBulkUpdateOrderStatusIsPerOrder(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			first := f.placeOrder(1)
+			second := f.placeOrder(1)
+
+			if _, err := f.orders.UpdateOrderStatus(f.ctx, f.admin, second.ID, domain.OrderStatusCancelled, "fraud"); err != nil {
+				t.Fatalf("UpdateOrderStatus: %v", err)
+			}
+
+			errs := f.orders.BulkUpdateOrderStatus(f.ctx, f.admin, []uint{first.ID, second.ID}, domain.OrderStatusPaid, "paid by bank transfer")
+			if errs[0] != nil {
+				t.Errorf("first order: %v", errs[0])
+			}
+			if !errors.Is(errs[1], domain.ErrInvalidState) {
+				t.Errorf("cancelled order: got %v, want ErrInvalidState", errs[1])
+			}
+
+			paid, total, err := f.orders.ListOrders(f.ctx, domain.OrderFilter{Status: domain.OrderStatusPaid, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 1 || len(paid) != 1 || paid[0].ID != first.ID {
+				t.Errorf("paid orders = %d of %d, want only order %d", len(paid), total, first.ID)
+			}
+
+			if _, err := f.orders.UpdateOrderStatus(f.ctx, f.admin, first.ID, domain.OrderStatusRefunded, ""); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("UpdateOrderStatus to refunded: got %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
+
+func TestListOrdersByCreationTime(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			order := f.placeOrder(1)
+
+			now := time.Now()
+			for _, tt := range []struct {
+				name     string
+				from, to time.Time
+				want     int
+			}{
+				{"around now", now.Add(-time.Minute), now.Add(time.Minute), 1},
+				{"before", now.Add(-time.Hour), now.Add(-time.Minute), 0},
+				{"after", now.Add(time.Minute), now.Add(time.Hour), 0},
+			} {
+				orders, total, err := f.orders.ListOrders(f.ctx, domain.OrderFilter{CreatedFrom: &tt.from, CreatedTo: &tt.to, Limit: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != tt.want || len(orders) != tt.want {
+					t.Errorf("%s: %d orders of %d, want %d", tt.name, len(orders), total, tt.want)
+				}
+				if len(orders) == 1 && orders[0].ID != order.ID {
+					t.Errorf("%s: order %d, want %d", tt.name, orders[0].ID, order.ID)
+				}
+			}
+		})
+	}
+}
@@ -1,149 +1,326 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"shop/internal/domain"
+	"shop/internal/gateway"
 	"shop/internal/repository"
 	"time"
 )
 
+// ErrInvalidCallback is returned for callbacks that fail signature checks or
+// do not match the charge recorded for the payment.
+var ErrInvalidCallback = domain.NewError(domain.ErrUnauthorized, "invalid payment callback")
+
+var errCartLocked = domain.NewError(domain.ErrCartLocked, "cart is already locked for payment")
+
+var errNotPending = domain.NewError(domain.ErrInvalidState, "payment is not in pending state")
+
 type PaymentUsecase struct {
-	paymentRepo repository.PaymentRepository
-	userRepo    repository.UserRepository
-	cartRepo    repository.CartItemsRepository
-	orderRepo   repository.OrderRepository
-	productRepo repository.ProductRepository
+	paymentRepo  repository.PaymentRepository
+	userRepo     repository.UserRepository
+	cartRepo     repository.CartItemsRepository
+	orderRepo    repository.OrderRepository
+	productRepo  repository.ProductRepository
+	gateway      gateway.PaymentGateway
+	uow          repository.UnitOfWork
+	lockTTL      time.Duration
+	reservations *ReservationService
 }
 
-func NewPaymentUseCase(paymentRepo repository.PaymentRepository, cartRepo repository.CartItemsRepository, orderRepo repository.OrderRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository) *PaymentUsecase {
+func NewPaymentUseCase(paymentRepo repository.PaymentRepository, cartRepo repository.CartItemsRepository, orderRepo repository.OrderRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository, paymentGateway gateway.PaymentGateway, uow repository.UnitOfWork, lockTTL time.Duration, reservations *ReservationService) *PaymentUsecase {
 	return &PaymentUsecase{
-		paymentRepo: paymentRepo,
-		cartRepo:    cartRepo,
-		orderRepo:   orderRepo,
-		productRepo: productRepo,
-		userRepo:    userRepo,
+		paymentRepo:  paymentRepo,
+		cartRepo:     cartRepo,
+		orderRepo:    orderRepo,
+		productRepo:  productRepo,
+		userRepo:     userRepo,
+		gateway:      paymentGateway,
+		uow:          uow,
+		lockTTL:      lockTTL,
+		reservations: reservations,
 	}
 }
 
 // InitiatePayment - شروع فرآیند پرداخت و قفل کردن سبد خرید
-func (p *PaymentUsecase) InitiatePayment(userID uint, paymentMethod string) (*domain.Payment, error) {
-	// دریافت کاربر
-	user, err := p.userRepo.GetByID(userID)
-	if err != nil {
-		return nil, errors.New("user not found")
-	}
+func (p *PaymentUsecase) InitiatePayment(ctx context.Context, userID uint, req *domain.PaymentRequest) (*domain.Payment, error) {
+	var payment *domain.Payment
+	err := p.uow.Do(ctx, func(repos repository.Repositories) error {
+		// دریافت کاربر
+		user, err := repos.Users.GetByID(ctx, userID)
+		if err != nil {
+			return notFound(err, "user")
+		}
 
-	// بررسی اینکه سبد خرید قفل نباشد
-	if user.LockCart {
-		return nil, errors.New("cart is already locked for payment")
-	}
+		// بررسی اینکه سبد خرید قفل نباشد
+		if user.CartLocked(time.Now()) {
+			return errCartLocked
+		}
 
-	// دریافت آیتم‌های سبد خرید
-	cartItems, err := p.cartRepo.GetByUserID(userID)
+		// دریافت آیتم‌های سبد خرید
+		cartItems, err := repos.Carts.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if len(cartItems) == 0 {
+			return domain.NewError(domain.ErrInvalidInput, "cart is empty")
+		}
+
+		// انتخاب آدرس‌های ارسال و صورتحساب؛ آدرس‌ها پس از تکمیل پرداخت در سفارش کپی می‌شوند
+		shipping, billing, err := resolveOrderAddresses(ctx, repos, userID, req.ShippingAddressID, req.BillingAddressID)
+		if err != nil {
+			return err
+		}
+
+		// محاسبه مجموع قیمت
+		var total float64
+		products := make([]*domain.Product, len(cartItems))
+		for i, item := range cartItems {
+			product, err := repos.Products.GetByID(ctx, item.ProductId)
+			if err != nil {
+				return notFound(err, "product")
+			}
+
+			products[i] = product
+			total += product.Price * float64(item.Quantity)
+		}
+
+		// ایجاد رکورد پرداخت
+		payment = &domain.Payment{
+			UserID:        userID,
+			Amount:        total,
+			Status:        domain.PaymentStatusPending,
+			PaymentMethod: req.PaymentMethod,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		if shipping != nil {
+			payment.ShippingAddressID = &shipping.ID
+		}
+		if billing != nil {
+			payment.BillingAddressID = &billing.ID
+		}
+
+		if err := repos.Payments.Create(ctx, payment); err != nil {
+			return err
+		}
+
+		// رزرو موجودی تا زمان تسویه پرداخت؛ واحدهایی که پرداخت‌های دیگر رزرو
+		// کرده‌اند قابل فروش نیستند
+		for i, item := range cartItems {
+			if err := p.reservations.Hold(ctx, repos, payment.ID, products[i], item.Quantity); err != nil {
+				return err
+			}
+		}
+
+		if err := p.lockCart(ctx, repos, payment); err != nil {
+			return err
+		}
+
+		user.TotalCart = total
+		return repos.Users.Update(ctx, user)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(cartItems) == 0 {
-		return nil, errors.New("cart is empty")
+	if err := p.charge(ctx, payment); err != nil {
+		return nil, err
 	}
+	return payment, nil
+}
 
-	// محاسبه مجموع قیمت و بررسی موجودی
-	var total float64
-	for _, item := range cartItems {
-		product, err := p.productRepo.GetByID(item.ProductId)
-		if err != nil {
-			return nil, errors.New("product not found")
-		}
+// lockCart - قفل کردن سبد خرید به نام payment؛ اگر درخواست هم‌زمان دیگری
+// زودتر قفل را گرفته باشد، کل تراکنش برگردانده می‌شود
+func (p *PaymentUsecase) lockCart(ctx context.Context, repos repository.Repositories, payment *domain.Payment) error {
+	err := repos.Users.LockCart(ctx, payment.UserID, payment.ID, time.Now().Add(p.lockTTL))
+	if errors.Is(err, domain.ErrCartLocked) {
+		return errCartLocked
+	}
+	return err
+}
 
-		if product.Stock < item.Quantity {
-			return nil, errors.New("insufficient stock for product: " + product.Name)
+// charge - ایجاد charge در درگاه پرداخت برای پرداخت ثبت‌شده؛ در صورت خطا
+// پرداخت ناموفق ثبت و سبد خرید آزاد می‌شود. پرداخت پیش از فراخوانی درگاه
+// ذخیره شده است، بنابراین callback درگاه حتی اگر زودتر از پاسخ آن برسد با
+// شناسه پرداخت پیدا می‌شود
+func (p *PaymentUsecase) charge(ctx context.Context, payment *domain.Payment) error {
+	charge, err := p.gateway.CreateCharge(ctx, payment)
+	if err != nil {
+		payment.Status = domain.PaymentStatusFailed
+		payment.GatewayResponse = err.Error()
+		if updateErr := p.paymentRepo.Update(ctx, payment); updateErr != nil {
+			log.Printf("Error marking payment %d as failed: %v", payment.ID, updateErr)
 		}
+		p.releaseCheckout(ctx, payment)
+		return err
+	}
 
-		total += product.Price * float64(item.Quantity)
+	// فقط شناسه تراکنش ثبت می‌شود تا وضعیتی که callback زودرس ثبت کرده بازنویسی نشود
+	payment.GatewayTransactionID = charge.TransactionID
+	return p.paymentRepo.SetGatewayTransactionID(ctx, payment.ID, charge.TransactionID)
+}
+
+// HandleCallback - بررسی امضای callback درگاه و پردازش پرداخت
+func (p *PaymentUsecase) HandleCallback(ctx context.Context, payload []byte, signature string) error {
+	callback, err := p.gateway.VerifyCallback(ctx, payload, signature)
+	if err != nil {
+		return ErrInvalidCallback
 	}
 
-	// قفل کردن سبد خرید
-	user.LockCart = true
-	user.TotalCart = total
-	if err := p.userRepo.Update(user); err != nil {
-		return nil, err
+	payment, err := p.paymentRepo.GetByID(ctx, callback.PaymentID)
+	if err != nil {
+		return notFound(err, "payment")
 	}
 
-	// ایجاد رکورد پرداخت
-	payment := &domain.Payment{
-		UserID:        userID,
-		Amount:        total,
-		Status:        "pending",
-		PaymentMethod: paymentMethod,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	// شناسه تراکنش ممکن است هنوز ثبت نشده باشد، اگر callback زودتر از پاسخ
+	// CreateCharge رسیده باشد
+	if payment.GatewayTransactionID != "" && payment.GatewayTransactionID != callback.TransactionID {
+		return ErrInvalidCallback
 	}
 
-	if err := p.paymentRepo.Create(payment); err != nil {
-		// در صورت خطا، قفل سبد خرید را باز کن
-		user.LockCart = false
-		p.userRepo.Update(user)
-		return nil, err
+	// وضعیت charge را مستقیماً از درگاه استعلام کن و به بدنه callback اکتفا نکن
+	charge, err := p.gateway.GetCharge(ctx, callback.TransactionID)
+	if err != nil {
+		return err
+	}
+	if charge.PaymentID != payment.ID {
+		return ErrInvalidCallback
 	}
 
-	return payment, nil
+	if charge.Status == domain.ChargeStatusPending {
+		return domain.NewError(domain.ErrInvalidState, "charge is not settled yet")
+	}
+
+	return p.ProcessPayment(ctx, payment.ID, domain.PaymentGatewayResponse{
+		Success:       charge.Status == domain.ChargeStatusSucceeded,
+		TransactionID: charge.TransactionID,
+		Message:       charge.Message,
+	})
 }
 
-// ProcessPayment - پردازش پرداخت (شبیه‌سازی gateway)
-func (p *PaymentUsecase) ProcessPayment(paymentID uint, gatewayResponse domain.PaymentGatewayResponse) error {
-	payment, err := p.paymentRepo.GetByID(paymentID)
+// ProcessPayment - پردازش نتیجه پرداخت دریافت‌شده از درگاه
+func (p *PaymentUsecase) ProcessPayment(ctx context.Context, paymentID uint, gatewayResponse domain.PaymentGatewayResponse) error {
+	payment, err := p.paymentRepo.GetByID(ctx, paymentID)
 	if err != nil {
-		return errors.New("payment not found")
+		return notFound(err, "payment")
 	}
 
-	if payment.Status != "pending" {
-		return errors.New("payment is not in pending state")
+	if payment.Status != domain.PaymentStatusPending {
+		return errNotPending
 	}
 
-	// شبیه‌سازی پردازش در gateway
-	if gatewayResponse.Success {
-		payment.Status = "completed"
+	payment.GatewayResponse = gatewayResponse.Message
+	payment.UpdatedAt = time.Now()
+	if gatewayResponse.TransactionID != "" {
 		payment.GatewayTransactionID = gatewayResponse.TransactionID
-		payment.GatewayResponse = gatewayResponse.Message
-	} else {
-		payment.Status = "failed"
-		payment.GatewayResponse = gatewayResponse.Message
 	}
 
-	payment.UpdatedAt = time.Now()
+	// اگر پرداخت ناموفق بود، وضعیت را ثبت و قفل سبد خرید را باز کن
+	if !gatewayResponse.Success {
+		// تغییر وضعیت شرطی است تا با callback تکراری یا reaper هم‌زمان تداخل نکند
+		n, err := p.paymentRepo.TransitionStatus(ctx, payment.ID, domain.PaymentStatusPending, domain.PaymentStatusFailed)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNotPending
+		}
 
-	if err := p.paymentRepo.Update(payment); err != nil {
-		return err
+		payment.Status = domain.PaymentStatusFailed
+		if err := p.paymentRepo.Update(ctx, payment); err != nil {
+			return err
+		}
+
+		p.releaseCheckout(ctx, payment)
+		return nil
 	}
 
-	// اگر پرداخت موفق بود، order ایجاد کن
-	if payment.Status == "completed" {
-		if err := p.createOrderFromCart(payment.UserID); err != nil {
-			log.Printf("Error creating order after successful payment: %v", err)
-			// در اینجا می‌توانید payment را به حالت failed تغییر دهید یا retry logic اضافه کنید
+	payment.Status = domain.PaymentStatusCompleted
+	orderID := payment.OrderID
+
+	// ثبت پرداخت موفق و ایجاد سفارش در یک تراکنش
+	err = p.uow.Do(ctx, func(repos repository.Repositories) error {
+		n, err := repos.Payments.TransitionStatus(ctx, payment.ID, domain.PaymentStatusPending, domain.PaymentStatusCompleted)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNotPending
+		}
+		// رزرو به کسر واقعی موجودی تبدیل می‌شود: رزرو حذف و موجودی در
+		// createOrderFromCart کم می‌شود
+		if err := repos.Reservations.DeleteByPaymentID(ctx, payment.ID); err != nil {
+			return err
 		}
-	} else {
-		// اگر پرداخت ناموفق بود، قفل سبد خرید را باز کن
-		if err := p.unlockCart(payment.UserID); err != nil {
-			log.Printf("Error unlocking cart after failed payment: %v", err)
+		// پرداخت checkout سفارش خود را از قبل دارد؛ در غیر این صورت سفارش
+		// از سبد خرید ساخته می‌شود
+		var order *domain.Order
+		if payment.OrderID != nil {
+			order, err = payCheckoutOrder(ctx, repos, payment)
+		} else {
+			order, err = p.createOrderFromCart(ctx, repos, payment)
 		}
+		if err != nil {
+			return err
+		}
+		// اتصال پرداخت به سفارشی که هزینه آن را پرداخت کرده است
+		payment.OrderID = &order.ID
+		if err := repos.Payments.Update(ctx, payment); err != nil {
+			return err
+		}
+		return p.clearCartAndUnlock(ctx, repos, payment)
+	})
+	if err == nil {
+		return nil
+	}
+	// تراکنش برگشت خورده و سفارشی که به پرداخت متصل شده بود دیگر وجود ندارد
+	payment.OrderID = orderID
+
+	if errors.Is(err, errNotPending) {
+		// callback تکراری برای پرداختی که قبلاً تسویه شده است
+		current, getErr := p.paymentRepo.GetByID(ctx, payment.ID)
+		if getErr != nil {
+			return getErr
+		}
+		if current.Status != domain.PaymentStatusCancelled {
+			return errNotPending
+		}
+		// پرداخت پیش از رسیدن callback لغو شده بود ولی درگاه مبلغ را گرفته است
+		err = errors.New("payment was cancelled before the charge succeeded")
+	}
+
+	// پول از مشتری گرفته شده ولی سفارش ساخته نشد؛ پرداخت نباید گم شود
+	// حتی اگر درخواست لغو شده باشد، این وضعیت باید ثبت شود
+	ctx = context.WithoutCancel(ctx)
+	log.Printf("Error creating order after successful payment %d: %v", payment.ID, err)
+	payment.Status = domain.PaymentStatusNeedsRefund
+	payment.GatewayResponse = gatewayResponse.Message + "; order creation failed: " + err.Error()
+	if err := p.paymentRepo.Update(ctx, payment); err != nil {
+		return fmt.Errorf("recording payment %d for refund: %w", payment.ID, err)
 	}
 
+	p.releaseCheckout(ctx, payment)
+
 	return nil
 }
 
 // createOrderFromCart - ایجاد سفارش از آیتم‌های سبد خرید
-func (p *PaymentUsecase) createOrderFromCart(userID uint) error {
+func (p *PaymentUsecase) createOrderFromCart(ctx context.Context, repos repository.Repositories, payment *domain.Payment) (*domain.Order, error) {
+	userID := payment.UserID
+
 	// دریافت آیتم‌های سبد خرید
-	cartItems, err := p.cartRepo.GetByUserID(userID)
+	cartItems, err := repos.Carts.GetByUserID(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(cartItems) == 0 {
-		return errors.New("cart is empty")
+		return nil, domain.NewError(domain.ErrInvalidInput, "cart is empty")
 	}
 
 	// محاسبه مجموع و ایجاد order items
@@ -151,14 +328,14 @@ func (p *PaymentUsecase) createOrderFromCart(userID uint) error {
 	var orderItems []domain.OrderItem
 
 	for _, item := range cartItems {
-		product, err := p.productRepo.GetByID(item.ProductId)
+		product, err := repos.Products.GetByID(ctx, item.ProductId)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// بررسی نهایی موجودی
 		if product.Stock < item.Quantity {
-			return errors.New("insufficient stock")
+			return nil, domain.InsufficientStock(product.ID, item.Quantity, product.Stock)
 		}
 
 		orderItem := domain.OrderItem{
@@ -175,97 +352,197 @@ func (p *PaymentUsecase) createOrderFromCart(userID uint) error {
 	order := &domain.Order{
 		UserID:    userID,
 		Total:     total,
-		Status:    "confirmed",
+		Status:    domain.OrderStatusPending,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := p.orderRepo.Create(order); err != nil {
-		return err
+	if err := repos.Orders.Create(ctx, order); err != nil {
+		return nil, err
+	}
+
+	// ثبت تاریخچه وضعیت: سفارش از سبد خرید ثبت و با این پرداخت پرداخت شد
+	if err := recordOrderCreated(ctx, repos, order, domain.Principal{UserID: userID}, "order placed from cart"); err != nil {
+		return nil, err
+	}
+	if err := transitionOrder(ctx, repos, order, domain.OrderStatusPaid, domain.Principal{}, fmt.Sprintf("payment %d completed", payment.ID)); err != nil {
+		return nil, err
+	}
+
+	// کپی آدرس‌های انتخاب‌شده هنگام پرداخت در سفارش؛ آدرسی که در این فاصله
+	// حذف شده باشد با آدرس پیش‌فرض جایگزین می‌شود
+	shipping, billing, err := resolveOrderAddresses(ctx, repos, userID, payment.ShippingAddressID, payment.BillingAddressID)
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshotOrderAddresses(ctx, repos, order, shipping, billing); err != nil {
+		return nil, err
 	}
 
 	// ایجاد order items و به‌روزرسانی موجودی
 	for _, item := range orderItems {
 		item.OrderID = order.ID
-		if err := p.orderRepo.CreateOrderItem(&item); err != nil {
-			return err
+		if err := repos.Orders.CreateOrderItem(ctx, &item); err != nil {
+			return nil, err
 		}
 
-		// کاهش موجودی محصول
-		product, _ := p.productRepo.GetByID(item.ProductID)
-		newStock := product.Stock - item.Quantity
-		if err := p.productRepo.UpdateStock(item.ProductID, newStock); err != nil {
-			return err
+		// کاهش موجودی محصول به‌صورت شرطی تا موجودی هرگز منفی نشود
+		if err := decrementStock(ctx, repos, item.ProductID, item.Quantity); err != nil {
+			return nil, err
 		}
 	}
 
-	// پاک کردن سبد خرید و باز کردن قفل
-	if err := p.clearCartAndUnlock(userID); err != nil {
-		return err
-	}
+	return order, nil
+}
 
-	return nil
+// payCheckoutOrder - پرداخت سفارشی که checkout در وضعیت awaiting_payment ساخته
+// است؛ موجودی هنگام checkout کم شده است
+func payCheckoutOrder(ctx context.Context, repos repository.Repositories, payment *domain.Payment) (*domain.Order, error) {
+	order, err := repos.Orders.GetByID(ctx, *payment.OrderID)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if err := transitionOrder(ctx, repos, order, domain.OrderStatusPaid, domain.Principal{}, fmt.Sprintf("payment %d completed", payment.ID)); err != nil {
+		return nil, err
+	}
+	return order, nil
 }
 
-// clearCartAndUnlock - پاک کردن سبد خرید و باز کردن قفل
-func (p *PaymentUsecase) clearCartAndUnlock(userID uint) error {
+// clearCartAndUnlock - پاک کردن سبد خرید و باز کردن قفلی که این پرداخت گرفته است
+func (p *PaymentUsecase) clearCartAndUnlock(ctx context.Context, repos repository.Repositories, payment *domain.Payment) error {
 	// پاک کردن آیتم‌های سبد خرید
-	if err := p.cartRepo.ClearCart(userID); err != nil {
+	if err := repos.Carts.ClearCart(ctx, payment.UserID); err != nil {
 		return err
 	}
 
 	// باز کردن قفل سبد خرید و صفر کردن مجموع
-	user, err := p.userRepo.GetByID(userID)
+	if err := repos.Users.UnlockCart(ctx, payment.UserID, payment.ID); err != nil {
+		return err
+	}
+
+	user, err := repos.Users.GetByID(ctx, payment.UserID)
 	if err != nil {
 		return err
 	}
 
-	user.LockCart = false
 	user.TotalCart = 0
+	return repos.Users.Update(ctx, user)
+}
 
-	return p.userRepo.Update(user)
+// unlockCart - فقط باز کردن قفل سبد خرید (برای حالت ناموفق)؛ اگر قفل دیگر
+// متعلق به این پرداخت نباشد، دست نخورده می‌ماند
+func (p *PaymentUsecase) unlockCart(ctx context.Context, payment *domain.Payment) error {
+	err := p.userRepo.UnlockCart(ctx, payment.UserID, payment.ID)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil
+	}
+	return err
 }
 
-// unlockCart - فقط باز کردن قفل سبد خرید (برای حالت ناموفق)
-func (p *PaymentUsecase) unlockCart(userID uint) error {
-	user, err := p.userRepo.GetByID(userID)
-	if err != nil {
-		return err
+// releaseCheckout - آزاد کردن رزرو موجودی و قفل سبد پرداختی که به سفارش
+// نرسید؛ خطاها فقط ثبت می‌شوند چون وضعیت پرداخت قبلاً ذخیره شده است
+func (p *PaymentUsecase) releaseCheckout(ctx context.Context, payment *domain.Payment) {
+	if err := p.reservations.Release(ctx, payment.ID); err != nil {
+		log.Printf("Error releasing stock held by payment %d: %v", payment.ID, err)
+	}
+	if err := p.unlockCart(ctx, payment); err != nil {
+		log.Printf("Error unlocking cart of payment %d: %v", payment.ID, err)
 	}
+	if err := p.cancelCheckoutOrder(ctx, payment); err != nil {
+		log.Printf("Error cancelling the order of payment %d: %v", payment.ID, err)
+	}
+}
 
-	user.LockCart = false
-	return p.userRepo.Update(user)
+// cancelCheckoutOrder - لغو سفارش checkout که پرداخت آن به نتیجه نرسید و
+// بازگرداندن موجودی آن؛ سفارشی که دیگر در انتظار پرداخت نیست دست نمی‌خورد
+func (p *PaymentUsecase) cancelCheckoutOrder(ctx context.Context, payment *domain.Payment) error {
+	if payment.OrderID == nil {
+		return nil
+	}
+	return p.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, *payment.OrderID)
+		if err != nil {
+			return notFound(err, "order")
+		}
+		if order.Status != domain.OrderStatusAwaitingPayment {
+			return nil
+		}
+		return cancelOrder(ctx, repos, order, domain.Principal{}, fmt.Sprintf("payment %d %s", payment.ID, payment.Status))
+	})
 }
 
 // GetPayment - دریافت اطلاعات پرداخت
-func (p *PaymentUsecase) GetPayment(paymentID uint) (*domain.Payment, error) {
-	return p.paymentRepo.GetByID(paymentID)
+func (p *PaymentUsecase) GetPayment(ctx context.Context, principal domain.Principal, paymentID uint) (*domain.Payment, error) {
+	payment, err := p.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, notFound(err, "payment")
+	}
+	if err := authorize(principal, payment.UserID, "payment"); err != nil {
+		return nil, err
+	}
+
+	// سفارشی که از این پرداخت ساخته شده است
+	if payment.OrderID != nil {
+		order, err := p.orderRepo.GetByID(ctx, *payment.OrderID)
+		if err != nil {
+			return nil, notFound(err, "order")
+		}
+		if err := loadOrderItems(ctx, p.orderRepo, order); err != nil {
+			return nil, err
+		}
+		payment.Order = order
+	}
+	return payment, nil
 }
 
 // GetUserPayments - دریافت تاریخچه پرداخت‌های کاربر
-func (p *PaymentUsecase) GetUserPayments(userID uint, page, limit int) ([]*domain.Payment, error) {
+func (p *PaymentUsecase) GetUserPayments(ctx context.Context, userID uint, page, limit int) ([]*domain.Payment, error) {
 	offset := (page - 1) * limit
-	return p.paymentRepo.GetByUserID(userID, limit, offset)
+	return p.paymentRepo.GetByUserID(ctx, userID, limit, offset)
 }
 
 // CancelPayment - لغو پرداخت و باز کردن قفل سبد
-func (p *PaymentUsecase) CancelPayment(paymentID uint) error {
-	payment, err := p.paymentRepo.GetByID(paymentID)
+func (p *PaymentUsecase) CancelPayment(ctx context.Context, principal domain.Principal, paymentID uint) error {
+	payment, err := p.paymentRepo.GetByID(ctx, paymentID)
 	if err != nil {
-		return errors.New("payment not found")
+		return notFound(err, "payment")
 	}
-
-	if payment.Status != "pending" {
-		return errors.New("only pending payments can be cancelled")
+	if err := authorize(principal, payment.UserID, "payment"); err != nil {
+		return err
 	}
 
-	payment.Status = "cancelled"
-	payment.UpdatedAt = time.Now()
+	return p.cancelPending(ctx, payment)
+}
 
-	if err := p.paymentRepo.Update(payment); err != nil {
+// StalePendingPayments - پرداخت‌هایی که بیش از maxAge در وضعیت pending مانده‌اند
+func (p *PaymentUsecase) StalePendingPayments(ctx context.Context, maxAge time.Duration, limit int) ([]*domain.Payment, error) {
+	return p.paymentRepo.GetPendingBefore(ctx, time.Now().Add(-maxAge), limit)
+}
+
+// ExpirePayment - لغو پرداخت رهاشده با همان منطق CancelPayment؛ اگر پرداخت
+// دیگر pending نباشد ErrInvalidState برمی‌گردد
+func (p *PaymentUsecase) ExpirePayment(ctx context.Context, payment *domain.Payment) error {
+	return p.cancelPending(ctx, payment)
+}
+
+// cancelPending - لغو شرطی پرداخت pending؛ فقط یک درخواست (کاربر، reaper یا
+// callback) می‌تواند وضعیت را از pending خارج کند
+func (p *PaymentUsecase) cancelPending(ctx context.Context, payment *domain.Payment) error {
+	n, err := p.paymentRepo.TransitionStatus(ctx, payment.ID, domain.PaymentStatusPending, domain.PaymentStatusCancelled)
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return domain.NewError(domain.ErrInvalidState, "only pending payments can be cancelled")
+	}
+	payment.Status = domain.PaymentStatusCancelled
 
-	// باز کردن قفل سبد خرید
-	return p.unlockCart(payment.UserID)
+	// آزاد کردن رزرو موجودی، باز کردن قفل سبد خرید و لغو سفارش checkout
+	if err := p.reservations.Release(ctx, payment.ID); err != nil {
+		return err
+	}
+	if err := p.unlockCart(ctx, payment); err != nil {
+		return err
+	}
+	return p.cancelCheckoutOrder(ctx, payment)
 }
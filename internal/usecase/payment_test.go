@@ -0,0 +1,365 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"shop/internal/domain"
+	"shop/internal/gateway"
+	"shop/internal/gateway/simulator"
+)
+
+// settled waits for the simulator to settle the charge of transactionID.
+func settled(t *testing.T, gw *simulator.Gateway, transactionID string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		charge, err := gw.GetCharge(context.Background(), transactionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if charge.Status != domain.ChargeStatusPending {
+			return
+		}
+	}
+	t.Fatalf("charge %s did not settle", transactionID)
+}
+
+func TestHandleCallbackSettlesPaymentOnce(t *testing.T) {
+	const secret = "callback-secret"
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			gw := simulator.New(simulator.Config{Outcome: simulator.OutcomeSuccess, Secret: secret})
+			f := newFixture(t, b, gw)
+			ctx, payments := f.ctx, f.payments
+			f.fillCart(2)
+
+			payment, err := payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+			if err != nil {
+				t.Fatalf("InitiatePayment: %v", err)
+			}
+			settled(t, gw, payment.GatewayTransactionID)
+
+			payload, err := json.Marshal(domain.PaymentCallback{PaymentID: payment.ID, TransactionID: payment.GatewayTransactionID, Success: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature := gateway.Sign([]byte(secret), payload)
+
+			forged, err := json.Marshal(domain.PaymentCallback{PaymentID: payment.ID, TransactionID: "sim_forged", Success: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, callback := range map[string]struct {
+				payload   []byte
+				signature string
+			}{
+				"unsigned":               {payload, ""},
+				"tampered":               {forged, signature},
+				"unknown transaction":    {forged, gateway.Sign([]byte(secret), forged)},
+				"signed by someone else": {payload, gateway.Sign([]byte("other-secret"), payload)},
+			} {
+				if err := payments.HandleCallback(ctx, callback.payload, callback.signature); !errors.Is(err, domain.ErrUnauthorized) {
+					t.Errorf("%s callback: err = %v, want %v", name, err, domain.ErrUnauthorized)
+				}
+			}
+
+			// The provider delivers the same callback twice at once.
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = payments.HandleCallback(ctx, payload, signature)
+				}(i)
+			}
+			wg.Wait()
+
+			var handled int
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					handled++
+				case !errors.Is(err, domain.ErrInvalidState):
+					t.Errorf("HandleCallback: unexpected error %v", err)
+				}
+			}
+			if handled != 1 {
+				t.Errorf("handled %d callbacks, want 1", handled)
+			}
+			if err := payments.HandleCallback(ctx, payload, signature); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("replayed callback: err = %v, want %v", err, domain.ErrInvalidState)
+			}
+
+			state := f.checkoutState(payment.ID)
+			if state.payment.Status != domain.PaymentStatusCompleted || state.payment.OrderID == nil {
+				t.Errorf("payment = %+v, want it completed with an order", state.payment)
+			}
+			if state.orders != 1 || state.stock != 3 || state.held != 0 || state.cart != 0 || state.locked {
+				t.Errorf("after settling: %+v, want one order, stock 3, nothing held and an empty unlocked cart", state)
+			}
+		})
+	}
+}
+
+func TestDeclinedPaymentReleasesCheckout(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, payments := f.ctx, f.payments
+			f.fillCart(2)
+
+			payment, err := payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+			if err != nil {
+				t.Fatalf("InitiatePayment: %v", err)
+			}
+			if state := f.checkoutState(payment.ID); state.held != 2 || !state.locked {
+				t.Fatalf("after InitiatePayment: %+v, want 2 units held and the cart locked", state)
+			}
+
+			declined := domain.PaymentGatewayResponse{Success: false, TransactionID: payment.GatewayTransactionID, Message: "declined"}
+			if err := payments.ProcessPayment(ctx, payment.ID, declined); err != nil {
+				t.Fatalf("ProcessPayment: %v", err)
+			}
+			if err := payments.ProcessPayment(ctx, payment.ID, declined); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("replayed decline: err = %v, want %v", err, domain.ErrInvalidState)
+			}
+
+			state := f.checkoutState(payment.ID)
+			if state.payment.Status != domain.PaymentStatusFailed {
+				t.Errorf("payment status = %q, want %q", state.payment.Status, domain.PaymentStatusFailed)
+			}
+			if state.orders != 0 || state.stock != 5 || state.held != 0 || state.cart != 1 || state.locked {
+				t.Errorf("after declining: %+v, want no order, stock 5, nothing held and the cart kept unlocked", state)
+			}
+		})
+	}
+}
+
+func TestFailedSettlementRollsBackAndFlagsRefund(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			tests := []struct {
+				name string
+				// fail makes settling payment fail once the order is created.
+				fail func(f *fixture, payment *domain.Payment) error
+				// stock left once the settlement rolled back.
+				stock int
+			}{
+				{
+					name: "stock ran out",
+					fail: func(f *fixture, payment *domain.Payment) error {
+						return f.repos.Products.UpdateStock(f.ctx, f.product.ID, 1)
+					},
+					stock: 1,
+				},
+				{
+					// Another payment took over the cart lock, so unlocking the
+					// cart fails after the order was created.
+					name: "cart lock taken over",
+					fail: func(f *fixture, payment *domain.Payment) error {
+						ctx, repos := f.ctx, f.repos
+						other := &domain.Payment{UserID: payment.UserID, Amount: 20, Status: domain.PaymentStatusPending, PaymentMethod: "card"}
+						if err := repos.Payments.Create(ctx, other); err != nil {
+							return err
+						}
+						if err := repos.Users.UnlockCart(ctx, payment.UserID, payment.ID); err != nil {
+							return err
+						}
+						return repos.Users.LockCart(ctx, payment.UserID, other.ID, time.Now().Add(time.Minute))
+					},
+					stock: 5,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					f := newFixture(t, b, nil)
+					ctx, payments := f.ctx, f.payments
+					f.fillCart(2)
+
+					payment, err := payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+					if err != nil {
+						t.Fatalf("InitiatePayment: %v", err)
+					}
+					if err := tt.fail(f, payment); err != nil {
+						t.Fatal(err)
+					}
+
+					err = payments.ProcessPayment(ctx, payment.ID, domain.PaymentGatewayResponse{Success: true, TransactionID: payment.GatewayTransactionID})
+					if err != nil {
+						t.Fatalf("ProcessPayment: %v", err)
+					}
+
+					state := f.checkoutState(payment.ID)
+					if state.payment.Status != domain.PaymentStatusNeedsRefund || state.payment.OrderID != nil {
+						t.Errorf("payment status = %q, order = %v; want %q without an order", state.payment.Status, state.payment.OrderID, domain.PaymentStatusNeedsRefund)
+					}
+					if state.orders != 0 || state.stock != tt.stock || state.held != 0 || state.cart != 1 {
+						t.Errorf("after rolling back: %+v, want no order, stock %d, nothing held and the cart kept", state, tt.stock)
+					}
+				})
+			}
+		})
+	}
+}
+
+// callbackFirst holds CreateCharge back until the callback of the charge was
+// handled, like a provider whose callback overtakes its response.
+type callbackFirst struct {
+	*simulator.Gateway
+	handled chan struct{}
+}
+
+func (g callbackFirst) CreateCharge(ctx context.Context, payment *domain.Payment) (*domain.Charge, error) {
+	charge, err := g.Gateway.CreateCharge(ctx, payment)
+	if err == nil {
+		<-g.handled
+	}
+	return charge, err
+}
+
+func TestCallbackBeforeChargeIsRecorded(t *testing.T) {
+	const secret = "callback-secret"
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			var f *fixture
+			var callbackErr error
+			handled := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handled)
+				payload, err := io.ReadAll(r.Body)
+				if err == nil {
+					err = f.payments.HandleCallback(r.Context(), payload, r.Header.Get(gateway.SignatureHeader))
+				}
+				if callbackErr = err; err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer server.Close()
+
+			gw := simulator.New(simulator.Config{Outcome: simulator.OutcomeSuccess, Secret: secret, CallbackURL: server.URL, Delay: 0})
+			f = newFixture(t, b, callbackFirst{gw, handled})
+			f.fillCart(2)
+
+			payment, err := f.payments.InitiatePayment(f.ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+			if err != nil {
+				t.Fatalf("InitiatePayment: %v", err)
+			}
+			if callbackErr != nil {
+				t.Fatalf("HandleCallback: %v", callbackErr)
+			}
+
+			state := f.checkoutState(payment.ID)
+			if state.payment.Status != domain.PaymentStatusCompleted || state.payment.GatewayTransactionID != payment.GatewayTransactionID {
+				t.Errorf("payment = %+v, want it completed with transaction %s", state.payment, payment.GatewayTransactionID)
+			}
+			if state.orders != 1 || state.stock != 3 || state.cart != 0 || state.locked {
+				t.Errorf("after settling: %+v, want one order, stock 3 and an empty unlocked cart", state)
+			}
+		})
+	}
+}
+
+func TestInitiatePaymentConcurrentlyLocksCartOnce(t *testing.T) {
+	const attempts = 2
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			f.fillCart(2)
+
+			var wg sync.WaitGroup
+			payments := make([]*domain.Payment, attempts)
+			errs := make([]error, attempts)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					payments[i], errs[i] = f.payments.InitiatePayment(f.ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+				}(i)
+			}
+			wg.Wait()
+
+			var initiated *domain.Payment
+			for i, err := range errs {
+				switch {
+				case err == nil:
+					if initiated != nil {
+						t.Errorf("payments %d and %d both locked the cart", initiated.ID, payments[i].ID)
+					}
+					initiated = payments[i]
+				case !errors.Is(err, domain.ErrCartLocked):
+					t.Errorf("InitiatePayment: got %v, want ErrCartLocked", err)
+				}
+			}
+			if initiated == nil {
+				t.Fatal("no payment locked the cart")
+			}
+
+			state := f.checkoutState(initiated.ID)
+			if state.payment.Status != domain.PaymentStatusPending || state.held != 2 || state.stock != 5 || !state.locked {
+				t.Errorf("after initiating: %+v, want one pending payment holding 2 units of 5 and the cart locked", state)
+			}
+		})
+	}
+}
+
+func TestPendingPaymentsAndReservationsExpireByTime(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, repos := f.ctx, f.repos
+			f.fillCart(2)
+
+			payment, err := f.payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+			if err != nil {
+				t.Fatalf("InitiatePayment: %v", err)
+			}
+			if stale, err := f.payments.StalePendingPayments(ctx, time.Hour, 10); err != nil || len(stale) != 0 {
+				t.Errorf("payments pending for an hour = %d (err %v), want none", len(stale), err)
+			}
+			if stale, err := f.payments.StalePendingPayments(ctx, -time.Second, 10); err != nil || len(stale) != 1 || stale[0].ID != payment.ID {
+				t.Errorf("payments pending since a second from now = %v (err %v), want payment %d", stale, err, payment.ID)
+			}
+
+			held := func() int {
+				t.Helper()
+				held, err := repos.Reservations.HeldQuantities(ctx, []uint{f.product.ID})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return held[f.product.ID]
+			}
+			// Each reservation belongs to a payment of its own.
+			reserve := func(quantity int, expiresAt time.Time) {
+				t.Helper()
+				other := &domain.Payment{UserID: f.buyer.ID, Amount: 10, Status: domain.PaymentStatusPending, PaymentMethod: "card"}
+				if err := repos.Payments.Create(ctx, other); err != nil {
+					t.Fatal(err)
+				}
+				res := &domain.StockReservation{PaymentID: other.ID, ProductID: f.product.ID, Quantity: quantity, ExpiresAt: expiresAt}
+				if n, err := repos.Reservations.Reserve(ctx, res); err != nil || n != 1 {
+					t.Fatalf("Reserve: %d rows, %v", n, err)
+				}
+			}
+			reserve(3, time.Now().Add(-time.Millisecond))
+			if got := held(); got != 2 {
+				t.Errorf("held with an expired reservation = %d, want 2", got)
+			}
+			reserve(1, time.Now().Add(time.Minute))
+			if got := held(); got != 3 {
+				t.Errorf("held with another live reservation = %d, want 3", got)
+			}
+		})
+	}
+}
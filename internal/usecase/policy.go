@@ -0,0 +1,19 @@
+package usecase
+
+import "shop/internal/domain"
+
+// authorize checks that principal may access a resource owned by ownerID.
+// Admins bypass the check. Other users get a not-found error rather than a
+// forbidden one, so the IDs of resources they do not own cannot be probed.
+func authorize(principal domain.Principal, ownerID uint, resource string) error {
+	if principal.IsAdmin() {
+		return nil
+	}
+	if principal.UserID == 0 {
+		return domain.NewError(domain.ErrForbidden, "authentication required")
+	}
+	if principal.UserID != ownerID {
+		return domain.NotFound(resource)
+	}
+	return nil
+}
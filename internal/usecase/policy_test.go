@@ -0,0 +1,92 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+)
+
+func TestOwnershipOfPerUserResources(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx := f.ctx
+
+			address := f.addAddress()
+			order := f.placeOrder(1)
+			f.fillCart(1)
+			payment, err := f.payments.InitiatePayment(ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stranger := domain.Principal{UserID: f.newUser("stranger", domain.RoleUser).ID, Role: domain.RoleUser}
+
+			access := map[string]func(principal domain.Principal) error{
+				"order": func(principal domain.Principal) error {
+					_, err := f.orders.GetOrder(ctx, principal, order.ID)
+					return err
+				},
+				"order detail": func(principal domain.Principal) error {
+					_, _, err := f.orders.GetOrderDetail(ctx, principal, order.ID)
+					return err
+				},
+				"payment": func(principal domain.Principal) error {
+					_, err := f.payments.GetPayment(ctx, principal, payment.ID)
+					return err
+				},
+				"refunds": func(principal domain.Principal) error {
+					_, err := f.refunds.GetRefunds(ctx, principal, payment.ID)
+					return err
+				},
+				"address": func(principal domain.Principal) error {
+					_, err := f.addresses.GetAddress(ctx, principal, address.ID)
+					return err
+				},
+				"cart": func(principal domain.Principal) error {
+					_, err := f.carts.GetCart(ctx, principal, f.buyer.ID)
+					return err
+				},
+			}
+			for name, get := range access {
+				if err := get(f.owner); err != nil {
+					t.Errorf("owner reading %s: %v", name, err)
+				}
+				if err := get(f.admin); err != nil {
+					t.Errorf("admin reading %s: %v", name, err)
+				}
+				// Other users cannot tell the resource from a missing one.
+				if err := get(stranger); !errors.Is(err, domain.ErrNotFound) {
+					t.Errorf("stranger reading %s: got %v, want ErrNotFound", name, err)
+				}
+				if err := get(domain.Principal{}); !errors.Is(err, domain.ErrForbidden) {
+					t.Errorf("anonymous reading %s: got %v, want ErrForbidden", name, err)
+				}
+			}
+
+			if _, err := f.orders.CancelOrder(ctx, stranger, order.ID, ""); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("stranger cancelling the order: got %v, want ErrNotFound", err)
+			}
+			if err := f.payments.CancelPayment(ctx, stranger, payment.ID); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("stranger cancelling the payment: got %v, want ErrNotFound", err)
+			}
+			if err := f.addresses.DeleteAddress(ctx, stranger, address.ID); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("stranger deleting the address: got %v, want ErrNotFound", err)
+			}
+			if err := f.carts.ClearCart(ctx, stranger, f.buyer.ID); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("stranger clearing the cart: got %v, want ErrNotFound", err)
+			}
+			if state := f.checkoutState(payment.ID); state.payment.Status != domain.PaymentStatusPending || state.cart != 1 {
+				t.Errorf("after the stranger's attempts: %+v, want the payment pending and the cart kept", state)
+			}
+
+			// Admins act on any user's resources.
+			if err := f.payments.CancelPayment(ctx, f.admin, payment.ID); err != nil {
+				t.Errorf("admin cancelling the payment: %v", err)
+			}
+			if _, err := f.orders.CancelOrder(ctx, f.admin, order.ID, ""); err != nil {
+				t.Errorf("admin cancelling the order: %v", err)
+			}
+		})
+	}
+}
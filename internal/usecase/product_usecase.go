@@ -1,47 +1,64 @@
 package usecase
 
 import (
+    "context"
     "shop/internal/domain"
     "shop/internal/repository"
 )
 
 type ProductUsecase struct {
-    productRepo repository.ProductRepository
+    productRepo  repository.ProductRepository
+    reservations *ReservationService
 }
 
-func NewProductUsecase(productRepo repository.ProductRepository) *ProductUsecase {
-    return &ProductUsecase{productRepo: productRepo}
+func NewProductUsecase(productRepo repository.ProductRepository, reservations *ReservationService) *ProductUsecase {
+    return &ProductUsecase{productRepo: productRepo, reservations: reservations}
 }
 
-func (u *ProductUsecase) CreateProduct(req *domain.ProductRequest) (*domain.Product, error) {
+func (u *ProductUsecase) CreateProduct(ctx context.Context, req *domain.ProductRequest) (*domain.Product, error) {
     product := &domain.Product{
         Name:        req.Name,
         Description: req.Description,
         Price:       req.Price,
         Stock:       req.Stock,
+        Available:   req.Stock,
         Category:    req.Category,
     }
 
-    if err := u.productRepo.Create(product); err != nil {
+    if err := u.productRepo.Create(ctx, product); err != nil {
         return nil, err
     }
 
     return product, nil
 }
 
-func (u *ProductUsecase) GetProduct(id uint) (*domain.Product, error) {
-    return u.productRepo.GetByID(id)
+func (u *ProductUsecase) GetProduct(ctx context.Context, id uint) (*domain.Product, error) {
+    product, err := u.productRepo.GetByID(ctx, id)
+    if err != nil {
+        return nil, notFound(err, "product")
+    }
+    if err := u.reservations.SetAvailable(ctx, product); err != nil {
+        return nil, err
+    }
+    return product, nil
 }
 
-func (u *ProductUsecase) GetProducts(page, limit int) ([]*domain.Product, error) {
+func (u *ProductUsecase) GetProducts(ctx context.Context, page, limit int) ([]*domain.Product, error) {
     offset := (page - 1) * limit
-    return u.productRepo.GetAll(limit, offset)
+    products, err := u.productRepo.GetAll(ctx, limit, offset)
+    if err != nil {
+        return nil, err
+    }
+    if err := u.reservations.SetAvailable(ctx, products...); err != nil {
+        return nil, err
+    }
+    return products, nil
 }
 
-func (u *ProductUsecase) UpdateProduct(id uint, req *domain.ProductRequest) (*domain.Product, error) {
-    product, err := u.productRepo.GetByID(id)
+func (u *ProductUsecase) UpdateProduct(ctx context.Context, id uint, req *domain.ProductRequest) (*domain.Product, error) {
+    product, err := u.productRepo.GetByID(ctx, id)
     if err != nil {
-        return nil, err
+        return nil, notFound(err, "product")
     }
 
     product.Name = req.Name
@@ -50,13 +67,16 @@ func (u *ProductUsecase) UpdateProduct(id uint, req *domain.ProductRequest) (*do
     product.Stock = req.Stock
     product.Category = req.Category
 
-    if err := u.productRepo.Update(product); err != nil {
+    if err := u.productRepo.Update(ctx, product); err != nil {
+        return nil, err
+    }
+    if err := u.reservations.SetAvailable(ctx, product); err != nil {
         return nil, err
     }
 
     return product, nil
 }
 
-func (u *ProductUsecase) DeleteProduct(id uint) error {
-    return u.productRepo.Delete(id)
+func (u *ProductUsecase) DeleteProduct(ctx context.Context, id uint) error {
+    return notFound(u.productRepo.Delete(ctx, id), "product")
 }
\ No newline at end of file
@@ -0,0 +1,340 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"shop/internal/domain"
+	"shop/internal/gateway"
+	"shop/internal/repository"
+)
+
+// refundableStatuses are the payment statuses that still hold money.
+var refundableStatuses = map[string]bool{
+	domain.PaymentStatusCompleted:         true,
+	domain.PaymentStatusPartiallyRefunded: true,
+	domain.PaymentStatusNeedsRefund:       true,
+}
+
+// RefundUsecase gives money of settled payments back through the payment
+// gateway. A refund is recorded as pending before the gateway is called, so
+// that concurrent requests cannot refund the same amount twice, and is marked
+// succeeded or failed once the gateway has answered.
+type RefundUsecase struct {
+	paymentRepo repository.PaymentRepository
+	refundRepo  repository.RefundRepository
+	gateway     gateway.PaymentGateway
+	uow         repository.UnitOfWork
+}
+
+func NewRefundUsecase(paymentRepo repository.PaymentRepository, refundRepo repository.RefundRepository, paymentGateway gateway.PaymentGateway, uow repository.UnitOfWork) *RefundUsecase {
+	return &RefundUsecase{
+		paymentRepo: paymentRepo,
+		refundRepo:  refundRepo,
+		gateway:     paymentGateway,
+		uow:         uow,
+	}
+}
+
+// RefundPayment refunds payment paymentID as described by req and derives the
+// new payment and order statuses from the refunds that have succeeded.
+// principal is recorded in the order's status history.
+func (u *RefundUsecase) RefundPayment(ctx context.Context, principal domain.Principal, paymentID uint, req *domain.RefundRequest) (*domain.Refund, error) {
+	var payment *domain.Payment
+	var refund *domain.Refund
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		var err error
+		payment, err = repos.Payments.GetByID(ctx, paymentID)
+		if err != nil {
+			return notFound(err, "payment")
+		}
+		if !refundableStatuses[payment.Status] {
+			return domain.NewError(domain.ErrInvalidState, "only completed payments can be refunded").
+				WithDetail("status", payment.Status)
+		}
+		if payment.GatewayTransactionID == "" {
+			return domain.NewError(domain.ErrInvalidState, "payment has no gateway transaction to refund")
+		}
+
+		refund, err = u.planRefund(ctx, repos, payment, req)
+		if err != nil {
+			return err
+		}
+		return repos.Refunds.Create(ctx, refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// From here on the refund exists; record its outcome even if the request
+	// is cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	result, err := u.gateway.Refund(ctx, payment.GatewayTransactionID, refund.Amount)
+	if err != nil {
+		refund.Status = domain.RefundStatusFailed
+		refund.GatewayResponse = err.Error()
+		if updateErr := u.refundRepo.Update(ctx, refund); updateErr != nil {
+			log.Printf("Error marking refund %d as failed: %v", refund.ID, updateErr)
+		}
+		return nil, fmt.Errorf("refunding payment %d: %w", payment.ID, err)
+	}
+
+	refund.Status = domain.RefundStatusSucceeded
+	refund.GatewayRefundID = result.RefundID
+	refund.GatewayResponse = result.Message
+	err = u.uow.Do(ctx, func(repos repository.Repositories) error {
+		if err := repos.Refunds.Update(ctx, refund); err != nil {
+			return err
+		}
+		if refund.Restock {
+			for _, item := range refund.Items {
+				n, err := repos.Products.IncrementStock(ctx, item.ProductID, item.Quantity)
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return domain.NotFound("product")
+				}
+			}
+		}
+		reason := fmt.Sprintf("refund %d", refund.ID)
+		if refund.Reason != "" {
+			reason += ": " + refund.Reason
+		}
+		return u.deriveStatuses(ctx, repos, payment, refund.OrderID, principal, reason)
+	})
+	if err != nil {
+		// The money has been returned, so the pending refund still counts
+		// against the payment; the rest has to be fixed by hand.
+		log.Printf("Error recording refund %d of payment %d after the gateway refunded it: %v", refund.ID, payment.ID, err)
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// planRefund builds the pending refund for req without storing it. Amounts of
+// pending and succeeded refunds count as already refunded.
+func (u *RefundUsecase) planRefund(ctx context.Context, repos repository.Repositories, payment *domain.Payment, req *domain.RefundRequest) (*domain.Refund, error) {
+	committed, err := repos.Refunds.TotalByPaymentID(ctx, payment.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	remaining := roundCents(payment.Amount - committed)
+
+	// Payments made since orders were linked to them can only refund their
+	// own order.
+	orderID := req.OrderID
+	if payment.OrderID != nil {
+		if orderID != nil && *orderID != *payment.OrderID {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order was not paid by this payment").
+				WithDetail("order_id", *orderID)
+		}
+		orderID = payment.OrderID
+	}
+
+	refund := &domain.Refund{
+		PaymentID: payment.ID,
+		OrderID:   orderID,
+		Status:    domain.RefundStatusPending,
+		Reason:    req.Reason,
+		Restock:   req.Restock,
+	}
+
+	if orderID == nil {
+		if len(req.Items) > 0 || req.Restock {
+			return nil, domain.NewError(domain.ErrInvalidInput, "refunding items requires an order_id")
+		}
+		refund.Amount = remaining
+	} else {
+		items, err := u.refundItems(ctx, repos, payment, *orderID, req.Items)
+		if err != nil {
+			return nil, err
+		}
+		refund.Items = items
+		for _, item := range items {
+			refund.Amount += item.Amount
+		}
+		refund.Amount = roundCents(refund.Amount)
+	}
+
+	if refund.Amount <= 0 {
+		return nil, domain.NewError(domain.ErrInvalidState, "nothing left to refund")
+	}
+	if refund.Amount > remaining {
+		return nil, domain.NewError(domain.ErrInvalidState, "refund exceeds the amount left on the payment").
+			WithDetail("amount", refund.Amount).
+			WithDetail("refundable", remaining)
+	}
+	return refund, nil
+}
+
+// refundItems resolves the requested items of order orderID, or every item
+// that has not been refunded yet when requested is empty.
+func (u *RefundUsecase) refundItems(ctx context.Context, repos repository.Repositories, payment *domain.Payment, orderID uint, requested []domain.RefundItemRequest) ([]domain.RefundItem, error) {
+	order, err := repos.Orders.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if order.UserID != payment.UserID {
+		return nil, domain.NewError(domain.ErrInvalidInput, "order does not belong to the payment's customer")
+	}
+
+	orderItems, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*domain.OrderItem, len(orderItems))
+	for _, item := range orderItems {
+		byID[item.ID] = item
+	}
+
+	if len(requested) == 0 {
+		for _, item := range orderItems {
+			if left := item.Quantity - refunded[item.ID]; left > 0 {
+				requested = append(requested, domain.RefundItemRequest{OrderItemID: item.ID, Quantity: left})
+			}
+		}
+	}
+
+	items := make([]domain.RefundItem, 0, len(requested))
+	seen := make(map[uint]bool, len(requested))
+	for _, req := range requested {
+		item, ok := byID[req.OrderItemID]
+		if !ok {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is not part of the order").
+				WithDetail("order_item_id", req.OrderItemID)
+		}
+		if seen[item.ID] {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is listed more than once").
+				WithDetail("order_item_id", item.ID)
+		}
+		seen[item.ID] = true
+
+		if left := item.Quantity - refunded[item.ID]; req.Quantity > left {
+			return nil, domain.NewError(domain.ErrInvalidInput, "quantity exceeds what is left to refund").
+				WithDetail("order_item_id", item.ID).
+				WithDetail("requested", req.Quantity).
+				WithDetail("refundable", left)
+		}
+
+		// The units are refunded after the ones already refunded, so that
+		// the rounding of the item's share comes out even.
+		before := refunded[item.ID]
+		items = append(items, domain.RefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    req.Quantity,
+			Amount:      roundCents(paidFor(order, orderItems, item, before+req.Quantity) - paidFor(order, orderItems, item, before)),
+		})
+	}
+	return items, nil
+}
+
+// paidFor returns the part of order's total that the first units of item
+// paid for. The total is what was charged, net of the discount and with
+// shipping and tax, and is spread over the items by their share of the
+// subtotal. The item with the highest ID takes what rounding leaves over, so
+// that the shares of every unit add up to the total.
+func paidFor(order *domain.Order, items []*domain.OrderItem, item *domain.OrderItem, units int) float64 {
+	var subtotal float64
+	last := item
+	for _, other := range items {
+		subtotal += other.Price * float64(other.Quantity)
+		if other.ID > last.ID {
+			last = other
+		}
+	}
+	if subtotal <= 0 || units <= 0 {
+		return 0
+	}
+	if item == last && units == item.Quantity {
+		rest := order.Total
+		for _, other := range items {
+			if other != item {
+				rest -= paidFor(order, items, other, other.Quantity)
+			}
+		}
+		return roundCents(rest)
+	}
+	return roundCents(order.Total * item.Price * float64(units) / subtotal)
+}
+
+// deriveStatuses sets the payment, and the order when there is one, to
+// refunded or partially_refunded from the refunds that have succeeded. A
+// payment that needs a refund keeps that status until it is fully refunded.
+func (u *RefundUsecase) deriveStatuses(ctx context.Context, repos repository.Repositories, payment *domain.Payment, orderID *uint, principal domain.Principal, reason string) error {
+	refundedAmount, err := repos.Refunds.TotalByPaymentID(ctx, payment.ID, domain.RefundStatusSucceeded)
+	if err != nil {
+		return err
+	}
+
+	current, err := repos.Payments.GetByID(ctx, payment.ID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case roundCents(refundedAmount) >= roundCents(current.Amount):
+		current.Status = domain.PaymentStatusRefunded
+	case current.Status != domain.PaymentStatusNeedsRefund:
+		current.Status = domain.PaymentStatusPartiallyRefunded
+	}
+	if err := repos.Payments.Update(ctx, current); err != nil {
+		return err
+	}
+	*payment = *current
+
+	if orderID == nil {
+		return nil
+	}
+
+	order, err := repos.Orders.GetByID(ctx, *orderID)
+	if err != nil {
+		return err
+	}
+	items, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusSucceeded)
+	if err != nil {
+		return err
+	}
+
+	status := domain.OrderStatusRefunded
+	for _, item := range items {
+		if refunded[item.ID] < item.Quantity {
+			status = domain.OrderStatusPartiallyRefunded
+			break
+		}
+	}
+	// Orders that cannot take the status, such as cancelled ones, keep theirs.
+	if status == order.Status || !domain.CanTransitionOrder(order.Status, status) {
+		return nil
+	}
+	return transitionOrder(ctx, repos, order, status, principal, reason)
+}
+
+// GetRefunds lists the refunds of a payment.
+func (u *RefundUsecase) GetRefunds(ctx context.Context, principal domain.Principal, paymentID uint) ([]*domain.Refund, error) {
+	payment, err := u.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, notFound(err, "payment")
+	}
+	if err := authorize(principal, payment.UserID, "payment"); err != nil {
+		return nil, err
+	}
+	return u.refundRepo.GetByPaymentID(ctx, paymentID)
+}
+
+// roundCents rounds amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
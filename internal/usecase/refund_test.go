@@ -0,0 +1,172 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+	"shop/internal/gateway/simulator"
+	"shop/internal/repository"
+)
+
+// paidOrder checks out the buyer's cart through gw and settles the charge,
+// and returns the completed payment and the order it paid for.
+func paidOrder(f *fixture, gw *simulator.Gateway) (*domain.Payment, *domain.Order) {
+	f.t.Helper()
+	payment, err := f.payments.InitiatePayment(f.ctx, f.buyer.ID, &domain.PaymentRequest{PaymentMethod: "card"})
+	if err != nil {
+		f.t.Fatalf("InitiatePayment: %v", err)
+	}
+	settled(f.t, gw, payment.GatewayTransactionID)
+	if err := f.payments.ProcessPayment(f.ctx, payment.ID, domain.PaymentGatewayResponse{Success: true, TransactionID: payment.GatewayTransactionID}); err != nil {
+		f.t.Fatalf("ProcessPayment: %v", err)
+	}
+
+	payment, err = f.repos.Payments.GetByID(f.ctx, payment.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if payment.OrderID == nil {
+		f.t.Fatalf("payment %d settled without an order", payment.ID)
+	}
+	order, err := f.orders.GetOrder(f.ctx, f.admin, *payment.OrderID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return payment, order
+}
+
+func TestRefundByLineItem(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			gw := simulator.New(simulator.Config{Outcome: simulator.OutcomeSuccess})
+			f := newFixture(t, b, gw)
+			ctx, refunds := f.ctx, f.refunds
+
+			gadget := &domain.Product{Name: "gadget", Price: 20, Stock: 5}
+			if err := f.repos.Products.Create(ctx, gadget); err != nil {
+				t.Fatal(err)
+			}
+			f.fillCart(3)
+			if err := f.repos.Carts.UpsertCartItem(ctx, f.buyer.ID, domain.CartItems{ProductId: gadget.ID, Quantity: 1, Fee: gadget.Price}, true); err != nil {
+				t.Fatal(err)
+			}
+			payment, order := paidOrder(f, gw)
+			if payment.Amount != 50 || f.stock() != 2 {
+				t.Fatalf("paid %v leaving %d widgets, want 50 leaving 2", payment.Amount, f.stock())
+			}
+			widgets := order.Items[0]
+			if widgets.ProductID != f.product.ID {
+				widgets = order.Items[1]
+			}
+
+			status := func(wantPayment, wantOrder string) {
+				t.Helper()
+				got, err := f.repos.Payments.GetByID(ctx, payment.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != wantPayment {
+					t.Errorf("payment status = %q, want %q", got.Status, wantPayment)
+				}
+				paid, err := f.orders.GetOrder(ctx, f.admin, order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if paid.Status != wantOrder {
+					t.Errorf("order status = %q, want %q", paid.Status, wantOrder)
+				}
+			}
+
+			refund, err := refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{
+				Items:   []domain.RefundItemRequest{{OrderItemID: widgets.ID, Quantity: 1}},
+				Restock: true,
+				Reason:  "damaged",
+			})
+			if err != nil {
+				t.Fatalf("RefundPayment: %v", err)
+			}
+			if refund.Status != domain.RefundStatusSucceeded || refund.Amount != 10 || len(refund.Items) != 1 {
+				t.Errorf("refund = %+v, want one widget refunded for 10", refund)
+			}
+			if got := f.stock(); got != 3 {
+				t.Errorf("stock after restocking = %d, want 3", got)
+			}
+			status(domain.PaymentStatusPartiallyRefunded, domain.OrderStatusPartiallyRefunded)
+
+			if _, err := refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{
+				Items: []domain.RefundItemRequest{{OrderItemID: widgets.ID, Quantity: 3}},
+			}); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("refunding more widgets than are left: got %v, want ErrInvalidInput", err)
+			}
+
+			// Without items, everything that is left is refunded.
+			rest, err := refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{})
+			if err != nil {
+				t.Fatalf("RefundPayment of the rest: %v", err)
+			}
+			if rest.Amount != 40 || len(rest.Items) != 2 {
+				t.Errorf("rest = %+v, want 2 widgets and the gadget refunded for 40", rest)
+			}
+			if got := f.stock(); got != 3 {
+				t.Errorf("stock after refunding without restock = %d, want 3", got)
+			}
+			status(domain.PaymentStatusRefunded, domain.OrderStatusRefunded)
+
+			if _, err := refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{}); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("refunding a refunded payment: got %v, want ErrInvalidState", err)
+			}
+			list, err := refunds.GetRefunds(ctx, f.owner, payment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 {
+				t.Errorf("refunds = %d, want 2", len(list))
+			}
+		})
+	}
+}
+
+func TestRefundNeverExceedsThePayment(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			gw := simulator.New(simulator.Config{Outcome: simulator.OutcomeSuccess})
+			f := newFixture(t, b, gw)
+			ctx := f.ctx
+			f.fillCart(3)
+			payment, order := paidOrder(f, gw)
+
+			// Another refund of 25 is still waiting for the gateway.
+			pending := &domain.Refund{PaymentID: payment.ID, OrderID: &order.ID, Amount: 25, Status: domain.RefundStatusPending}
+			if err := f.uow.Do(ctx, func(repos repository.Repositories) error {
+				return repos.Refunds.Create(ctx, pending)
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := f.refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{
+				Items: []domain.RefundItemRequest{{OrderItemID: order.Items[0].ID, Quantity: 3}},
+			})
+			if !errors.Is(err, domain.ErrInvalidState) {
+				t.Fatalf("refunding 30 of the 5 left: got %v, want ErrInvalidState", err)
+			}
+
+			if _, err := f.refunds.RefundPayment(ctx, f.admin, payment.ID, &domain.RefundRequest{
+				Items: []domain.RefundItemRequest{{OrderItemID: order.Items[0].ID, Quantity: 0}},
+			}); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("refunding nothing: got %v, want ErrInvalidState", err)
+			}
+
+			got, err := f.repos.Payments.GetByID(ctx, payment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != domain.PaymentStatusCompleted {
+				t.Errorf("payment status = %q, want it still %q", got.Status, domain.PaymentStatusCompleted)
+			}
+			if list, err := f.refunds.GetRefunds(ctx, f.admin, payment.ID); err != nil || len(list) != 1 {
+				t.Errorf("refunds = %d (err %v), want only the pending one", len(list), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"context"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"time"
+)
+
+// ReservationService holds stock for pending payments so that other
+// customers cannot buy the same units before the payment settles. Holds
+// lapse on their own once the TTL has passed.
+type ReservationService struct {
+	reservations repository.ReservationRepository
+	ttl          time.Duration
+}
+
+func NewReservationService(reservations repository.ReservationRepository, ttl time.Duration) *ReservationService {
+	return &ReservationService{reservations: reservations, ttl: ttl}
+}
+
+// Hold reserves quantity units of product for paymentID inside the unit of
+// work that repos belongs to. It fails with ErrInsufficientStock when the
+// units not held by other payments do not cover quantity.
+func (s *ReservationService) Hold(ctx context.Context, repos repository.Repositories, paymentID uint, product *domain.Product, quantity int) error {
+	n, err := repos.Reservations.Reserve(ctx, &domain.StockReservation{
+		PaymentID: paymentID,
+		ProductID: product.ID,
+		Quantity:  quantity,
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	held, err := repos.Reservations.HeldQuantities(ctx, []uint{product.ID})
+	if err != nil {
+		return err
+	}
+	return domain.InsufficientStock(product.ID, quantity, product.Stock-held[product.ID])
+}
+
+// Release drops every hold of paymentID.
+func (s *ReservationService) Release(ctx context.Context, paymentID uint) error {
+	return s.reservations.DeleteByPaymentID(ctx, paymentID)
+}
+
+// SetAvailable fills in the Available stock of products.
+func (s *ReservationService) SetAvailable(ctx context.Context, products ...*domain.Product) error {
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	held, err := s.reservations.HeldQuantities(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		product.Available = product.Stock - held[product.ID]
+		if product.Available < 0 {
+			product.Available = 0
+		}
+	}
+	return nil
+}
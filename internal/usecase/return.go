@@ -0,0 +1,319 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"shop/internal/domain"
+	"shop/internal/repository"
+)
+
+// returnableStatuses are the order statuses in which shipped units can be
+// returned. Processing orders may have shipped part of their units.
+var returnableStatuses = map[string]bool{
+	domain.OrderStatusProcessing:        true,
+	domain.OrderStatusShipped:           true,
+	domain.OrderStatusDelivered:         true,
+	domain.OrderStatusPartiallyRefunded: true,
+}
+
+// ReturnUsecase takes shipped units back from customers. A return is approved
+// or rejected, received and inspected; accepting it restocks the units and
+// refunds them through RefundUsecase.
+type ReturnUsecase struct {
+	orderRepo   repository.OrderRepository
+	paymentRepo repository.PaymentRepository
+	returnRepo  repository.ReturnRepository
+	uow         repository.UnitOfWork
+	refunds     *RefundUsecase
+}
+
+func NewReturnUsecase(orderRepo repository.OrderRepository, paymentRepo repository.PaymentRepository, returnRepo repository.ReturnRepository, uow repository.UnitOfWork, refunds *RefundUsecase) *ReturnUsecase {
+	return &ReturnUsecase{
+		orderRepo:   orderRepo,
+		paymentRepo: paymentRepo,
+		returnRepo:  returnRepo,
+		uow:         uow,
+		refunds:     refunds,
+	}
+}
+
+// RequestReturn asks to return units of order orderID as described by req.
+func (u *ReturnUsecase) RequestReturn(ctx context.Context, principal domain.Principal, orderID uint, req *domain.CreateReturnRequest) (*domain.Return, error) {
+	var ret *domain.Return
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, orderID)
+		if err != nil {
+			return notFound(err, "order")
+		}
+		if err := authorize(principal, order.UserID, "order"); err != nil {
+			return err
+		}
+		if !returnableStatuses[order.Status] {
+			return domain.NewError(domain.ErrInvalidState, "order has not shipped").
+				WithDetail("status", order.Status)
+		}
+
+		items, err := planReturn(ctx, repos, order, req.Items)
+		if err != nil {
+			return err
+		}
+
+		ret = &domain.Return{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			Status:  domain.ReturnStatusRequested,
+			Reason:  req.Reason,
+			Items:   items,
+		}
+		return repos.Returns.Create(ctx, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// planReturn resolves the requested items of order. A unit can be returned
+// once it has shipped, as long as it is neither part of another return nor
+// refunded or about to be.
+func planReturn(ctx context.Context, repos repository.Repositories, order *domain.Order, requested []domain.ReturnItemRequest) ([]domain.ReturnItem, error) {
+	orderItems, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	shipped, err := repos.Shipments.ShippedQuantities(ctx, order.ID, domain.ShipmentStatusShipped, domain.ShipmentStatusDelivered)
+	if err != nil {
+		return nil, err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	// returned holds every unit sent back or on its way; awaitingRefund the
+	// units of returns that refunded does not cover yet.
+	returned, err := repos.Returns.ReturnedQuantities(ctx, order.ID,
+		domain.ReturnStatusRequested, domain.ReturnStatusApproved, domain.ReturnStatusReceived,
+		domain.ReturnStatusAccepted, domain.ReturnStatusRefunded)
+	if err != nil {
+		return nil, err
+	}
+	awaitingRefund, err := repos.Returns.ReturnedQuantities(ctx, order.ID,
+		domain.ReturnStatusRequested, domain.ReturnStatusApproved, domain.ReturnStatusReceived)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*domain.OrderItem, len(orderItems))
+	for _, item := range orderItems {
+		byID[item.ID] = item
+	}
+
+	items := make([]domain.ReturnItem, 0, len(requested))
+	seen := make(map[uint]bool, len(requested))
+	for _, req := range requested {
+		item, ok := byID[req.OrderItemID]
+		if !ok {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is not part of the order").
+				WithDetail("order_item_id", req.OrderItemID)
+		}
+		if seen[item.ID] {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is listed more than once").
+				WithDetail("order_item_id", item.ID)
+		}
+		seen[item.ID] = true
+
+		left := shipped[item.ID] - returned[item.ID]
+		if unpaid := item.Quantity - refunded[item.ID] - awaitingRefund[item.ID]; unpaid < left {
+			left = unpaid
+		}
+		if req.Quantity > left {
+			return nil, domain.NewError(domain.ErrInvalidInput, "quantity exceeds what can be returned").
+				WithDetail("order_item_id", item.ID).
+				WithDetail("requested", req.Quantity).
+				WithDetail("returnable", max(left, 0))
+		}
+
+		items = append(items, domain.ReturnItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    req.Quantity,
+		})
+	}
+	return items, nil
+}
+
+// GetReturn returns a return with its items.
+func (u *ReturnUsecase) GetReturn(ctx context.Context, principal domain.Principal, id uint) (*domain.Return, error) {
+	ret, err := u.returnRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "return")
+	}
+	if err := authorize(principal, ret.UserID, "return"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetOrderReturns lists the returns of an order, newest first.
+func (u *ReturnUsecase) GetOrderReturns(ctx context.Context, principal domain.Principal, orderID uint) ([]*domain.Return, error) {
+	order, err := u.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if err := authorize(principal, order.UserID, "order"); err != nil {
+		return nil, err
+	}
+	return u.returnRepo.List(ctx, domain.ReturnFilter{OrderID: orderID})
+}
+
+// ListReturns lists the returns selected by filter, newest first.
+func (u *ReturnUsecase) ListReturns(ctx context.Context, filter domain.ReturnFilter) ([]*domain.Return, error) {
+	return u.returnRepo.List(ctx, filter)
+}
+
+// CancelReturn withdraws a return that has not been received yet.
+func (u *ReturnUsecase) CancelReturn(ctx context.Context, principal domain.Principal, id uint) (*domain.Return, error) {
+	var ret *domain.Return
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		var err error
+		ret, err = repos.Returns.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "return")
+		}
+		if err := authorize(principal, ret.UserID, "return"); err != nil {
+			return err
+		}
+		return transitionReturn(ctx, repos, ret, domain.ReturnStatusCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// UpdateReturnStatus moves a return along on behalf of an admin. Accepting it
+// restocks its units, unless req.Restock is false, and refunds them. Moving
+// an accepted return to refunded retries a refund that failed.
+func (u *ReturnUsecase) UpdateReturnStatus(ctx context.Context, principal domain.Principal, id uint, req *domain.UpdateReturnStatusRequest) (*domain.Return, error) {
+	var ret *domain.Return
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		var err error
+		ret, err = repos.Returns.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "return")
+		}
+		// Refunded is reached by refunding, which happens below.
+		if req.Status == domain.ReturnStatusRefunded {
+			if ret.Status != domain.ReturnStatusAccepted {
+				return domain.NewError(domain.ErrInvalidState, "only accepted returns can be refunded").
+					WithDetail("status", ret.Status)
+			}
+			return nil
+		}
+
+		if err := transitionReturn(ctx, repos, ret, req.Status); err != nil {
+			return err
+		}
+		if req.Note != "" {
+			ret.Note = req.Note
+		}
+
+		if ret.Status == domain.ReturnStatusAccepted && (req.Restock == nil || *req.Restock) {
+			for _, item := range ret.Items {
+				n, err := repos.Products.IncrementStock(ctx, item.ProductID, item.Quantity)
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return domain.NotFound("product")
+				}
+			}
+			ret.Restocked = true
+		}
+		return repos.Returns.Update(ctx, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.Status != domain.ReturnStatusAccepted {
+		return ret, nil
+	}
+
+	// The goods are back; refund them even if the request is cancelled in
+	// the meantime.
+	if err := u.refund(context.WithoutCancel(ctx), principal, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// refund refunds the units of an accepted return from the payment that paid
+// for its order and marks the return refunded. A return whose refund fails
+// stays accepted so that the refund can be retried.
+func (u *ReturnUsecase) refund(ctx context.Context, principal domain.Principal, ret *domain.Return) error {
+	payments, err := u.paymentRepo.GetByOrderID(ctx, ret.OrderID)
+	if err != nil {
+		return err
+	}
+	var payment *domain.Payment
+	for _, p := range payments {
+		if refundableStatuses[p.Status] {
+			payment = p
+			break
+		}
+	}
+	if payment == nil {
+		return domain.NewError(domain.ErrInvalidState, "order has no settled payment to refund").
+			WithDetail("return_id", ret.ID)
+	}
+
+	orderID := ret.OrderID
+	req := &domain.RefundRequest{
+		OrderID: &orderID,
+		Items:   make([]domain.RefundItemRequest, len(ret.Items)),
+		Reason:  fmt.Sprintf("return %d", ret.ID),
+	}
+	if ret.Reason != "" {
+		req.Reason += ": " + ret.Reason
+	}
+	for i, item := range ret.Items {
+		req.Items[i] = domain.RefundItemRequest{OrderItemID: item.OrderItemID, Quantity: item.Quantity}
+	}
+	refund, err := u.refunds.RefundPayment(ctx, principal, payment.ID, req)
+	if err != nil {
+		return err
+	}
+
+	return u.uow.Do(ctx, func(repos repository.Repositories) error {
+		if err := transitionReturn(ctx, repos, ret, domain.ReturnStatusRefunded); err != nil {
+			return err
+		}
+		ret.RefundID = &refund.ID
+		return repos.Returns.Update(ctx, ret)
+	})
+}
+
+// transitionReturn moves ret to status to inside the unit of work that repos
+// belongs to. It fails with ErrInvalidState when the return cannot take the
+// status, and with ErrConflict when its status changed since it was read.
+func transitionReturn(ctx context.Context, repos repository.Repositories, ret *domain.Return, to string) error {
+	if !domain.CanTransitionReturn(ret.Status, to) {
+		return domain.NewError(domain.ErrInvalidState, "return cannot move to this status").
+			WithDetail("from", ret.Status).
+			WithDetail("to", to)
+	}
+
+	n, err := repos.Returns.TransitionStatus(ctx, ret.ID, ret.Status, to)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.NewError(domain.ErrConflict, "return status was changed by another request")
+	}
+
+	ret.Status = to
+	return nil
+}
@@ -0,0 +1,60 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+)
+
+func TestReturnsClaimShippedUnitsOnce(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, owner, admin := f.ctx, f.owner, f.admin
+
+			order := f.placeOrder(3)
+			paid, err := f.orders.UpdateOrderStatus(ctx, admin, order.ID, domain.OrderStatusPaid, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			itemID := paid.Items[0].ID
+			request := func(quantity int) (*domain.Return, error) {
+				return f.returns.RequestReturn(ctx, owner, order.ID, &domain.CreateReturnRequest{
+					Reason: "broken",
+					Items:  []domain.ReturnItemRequest{{OrderItemID: itemID, Quantity: quantity}},
+				})
+			}
+
+			if _, err := request(1); !errors.Is(err, domain.ErrInvalidState) {
+				t.Errorf("return before shipping: got %v, want ErrInvalidState", err)
+			}
+
+			if _, err := f.shipments.CreateShipment(ctx, admin, order.ID, &domain.CreateShipmentRequest{
+				Carrier: "ups",
+				Status:  domain.ShipmentStatusShipped,
+				Items:   []domain.ShipmentItemRequest{{OrderItemID: itemID, Quantity: 2}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := request(3); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("returning unshipped units: got %v, want ErrInvalidInput", err)
+			}
+			first, err := request(2)
+			if err != nil {
+				t.Fatalf("RequestReturn: %v", err)
+			}
+			if _, err := request(1); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("returning units claimed by another return: got %v, want ErrInvalidInput", err)
+			}
+
+			if _, err := f.returns.CancelReturn(ctx, owner, first.ID); err != nil {
+				t.Fatalf("CancelReturn: %v", err)
+			}
+			if _, err := request(2); err != nil {
+				t.Errorf("RequestReturn after cancelling: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"shop/internal/domain"
+	"shop/internal/repository"
+	"time"
+)
+
+// shippableStatuses are the order statuses in which units can still be
+// shipped.
+var shippableStatuses = map[string]bool{
+	domain.OrderStatusPaid:              true,
+	domain.OrderStatusProcessing:        true,
+	domain.OrderStatusPartiallyRefunded: true,
+}
+
+// ShipmentUsecase fulfils paid orders. An order can be split across several
+// shipments, and its status follows them: processing once a shipment exists,
+// shipped once every unit not refunded has left, and delivered once every
+// such unit has arrived.
+type ShipmentUsecase struct {
+	orderRepo    repository.OrderRepository
+	shipmentRepo repository.ShipmentRepository
+	uow          repository.UnitOfWork
+}
+
+func NewShipmentUsecase(orderRepo repository.OrderRepository, shipmentRepo repository.ShipmentRepository, uow repository.UnitOfWork) *ShipmentUsecase {
+	return &ShipmentUsecase{
+		orderRepo:    orderRepo,
+		shipmentRepo: shipmentRepo,
+		uow:          uow,
+	}
+}
+
+// CreateShipment ships units of order orderID as described by req.
+func (u *ShipmentUsecase) CreateShipment(ctx context.Context, principal domain.Principal, orderID uint, req *domain.CreateShipmentRequest) (*domain.Shipment, error) {
+	var shipment *domain.Shipment
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		order, err := repos.Orders.GetByID(ctx, orderID)
+		if err != nil {
+			return notFound(err, "order")
+		}
+		if !shippableStatuses[order.Status] {
+			return domain.NewError(domain.ErrInvalidState, "order cannot be shipped").
+				WithDetail("status", order.Status)
+		}
+
+		items, err := planShipment(ctx, repos, order, req.Items)
+		if err != nil {
+			return err
+		}
+
+		shipment = &domain.Shipment{
+			OrderID:        order.ID,
+			Carrier:        req.Carrier,
+			TrackingNumber: req.TrackingNumber,
+			Status:         domain.ShipmentStatusPending,
+			Items:          items,
+		}
+		if req.Status == domain.ShipmentStatusShipped {
+			now := time.Now()
+			shipment.Status = domain.ShipmentStatusShipped
+			shipment.ShippedAt = &now
+		}
+		if err := repos.Shipments.Create(ctx, shipment); err != nil {
+			return err
+		}
+
+		reason := fmt.Sprintf("shipment %d created", shipment.ID)
+		if shipment.Status == domain.ShipmentStatusShipped {
+			reason = fmt.Sprintf("shipment %d shipped", shipment.ID)
+		}
+		return fulfilOrder(ctx, repos, order, principal, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// planShipment resolves the requested items of order, or every unit that has
+// been neither shipped nor refunded yet when requested is empty.
+func planShipment(ctx context.Context, repos repository.Repositories, order *domain.Order, requested []domain.ShipmentItemRequest) ([]domain.ShipmentItem, error) {
+	orderItems, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	shipped, err := repos.Shipments.ShippedQuantities(ctx, order.ID,
+		domain.ShipmentStatusPending, domain.ShipmentStatusShipped, domain.ShipmentStatusDelivered)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*domain.OrderItem, len(orderItems))
+	for _, item := range orderItems {
+		byID[item.ID] = item
+	}
+
+	if len(requested) == 0 {
+		for _, item := range orderItems {
+			if left := item.Quantity - refunded[item.ID] - shipped[item.ID]; left > 0 {
+				requested = append(requested, domain.ShipmentItemRequest{OrderItemID: item.ID, Quantity: left})
+			}
+		}
+		if len(requested) == 0 {
+			return nil, domain.NewError(domain.ErrInvalidState, "nothing left to ship")
+		}
+	}
+
+	items := make([]domain.ShipmentItem, 0, len(requested))
+	seen := make(map[uint]bool, len(requested))
+	for _, req := range requested {
+		item, ok := byID[req.OrderItemID]
+		if !ok {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is not part of the order").
+				WithDetail("order_item_id", req.OrderItemID)
+		}
+		if seen[item.ID] {
+			return nil, domain.NewError(domain.ErrInvalidInput, "order item is listed more than once").
+				WithDetail("order_item_id", item.ID)
+		}
+		seen[item.ID] = true
+
+		if left := item.Quantity - refunded[item.ID] - shipped[item.ID]; req.Quantity > left {
+			return nil, domain.NewError(domain.ErrInvalidInput, "quantity exceeds what is left to ship").
+				WithDetail("order_item_id", item.ID).
+				WithDetail("requested", req.Quantity).
+				WithDetail("shippable", left)
+		}
+
+		items = append(items, domain.ShipmentItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    req.Quantity,
+		})
+	}
+	return items, nil
+}
+
+// UpdateShipment changes the tracking details of shipment id or moves it
+// along, and advances its order when that completes a stage of fulfilment.
+func (u *ShipmentUsecase) UpdateShipment(ctx context.Context, principal domain.Principal, id uint, req *domain.UpdateShipmentRequest) (*domain.Shipment, error) {
+	var shipment *domain.Shipment
+	err := u.uow.Do(ctx, func(repos repository.Repositories) error {
+		var err error
+		shipment, err = repos.Shipments.GetByID(ctx, id)
+		if err != nil {
+			return notFound(err, "shipment")
+		}
+
+		if req.Carrier != nil {
+			shipment.Carrier = *req.Carrier
+		}
+		if req.TrackingNumber != nil {
+			shipment.TrackingNumber = *req.TrackingNumber
+		}
+
+		moved := req.Status != "" && req.Status != shipment.Status
+		if moved {
+			if !domain.CanTransitionShipment(shipment.Status, req.Status) {
+				return domain.NewError(domain.ErrInvalidState, "shipment cannot move to this status").
+					WithDetail("from", shipment.Status).
+					WithDetail("to", req.Status)
+			}
+			shipment.Status = req.Status
+		}
+		if err := setShipmentTimes(shipment, req); err != nil {
+			return err
+		}
+
+		if err := repos.Shipments.Update(ctx, shipment); err != nil {
+			return err
+		}
+		if !moved {
+			return nil
+		}
+
+		order, err := repos.Orders.GetByID(ctx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		return fulfilOrder(ctx, repos, order, principal, fmt.Sprintf("shipment %d %s", shipment.ID, shipment.Status))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// GetShipments lists the shipments of an order with their tracking details.
+func (u *ShipmentUsecase) GetShipments(ctx context.Context, principal domain.Principal, orderID uint) ([]*domain.Shipment, error) {
+	order, err := u.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, "order")
+	}
+	if err := authorize(principal, order.UserID, "order"); err != nil {
+		return nil, err
+	}
+	return u.shipmentRepo.GetByOrderID(ctx, orderID)
+}
+
+// setShipmentTimes applies the timestamps of req to shipment, and stamps the
+// stages it has reached without one with the current time.
+func setShipmentTimes(shipment *domain.Shipment, req *domain.UpdateShipmentRequest) error {
+	shipped := shipment.Status == domain.ShipmentStatusShipped || shipment.Status == domain.ShipmentStatusDelivered
+	delivered := shipment.Status == domain.ShipmentStatusDelivered
+
+	if req.ShippedAt != nil {
+		if !shipped {
+			return domain.NewError(domain.ErrInvalidInput, "shipment has not shipped")
+		}
+		shipment.ShippedAt = req.ShippedAt
+	}
+	if req.DeliveredAt != nil {
+		if !delivered {
+			return domain.NewError(domain.ErrInvalidInput, "shipment has not been delivered")
+		}
+		shipment.DeliveredAt = req.DeliveredAt
+	}
+
+	now := time.Now()
+	if delivered && shipment.DeliveredAt == nil {
+		shipment.DeliveredAt = &now
+	}
+	if shipped && shipment.ShippedAt == nil {
+		// A parcel handed over on the spot ships when it is delivered.
+		shipment.ShippedAt = &now
+		if shipment.DeliveredAt != nil && shipment.DeliveredAt.Before(now) {
+			shipment.ShippedAt = shipment.DeliveredAt
+		}
+	}
+
+	if shipment.ShippedAt != nil && shipment.DeliveredAt != nil && shipment.DeliveredAt.Before(*shipment.ShippedAt) {
+		return domain.NewError(domain.ErrInvalidInput, "shipment cannot be delivered before it shipped")
+	}
+	return nil
+}
+
+// fulfilOrder moves order to the stage of fulfilment its shipments have
+// reached. Units that are refunded, or being refunded, are not expected to
+// ship. Orders that cannot take the status keep theirs.
+func fulfilOrder(ctx context.Context, repos repository.Repositories, order *domain.Order, principal domain.Principal, reason string) error {
+	items, err := repos.Orders.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	refunded, err := repos.Refunds.RefundedQuantities(ctx, order.ID, domain.RefundStatusPending, domain.RefundStatusSucceeded)
+	if err != nil {
+		return err
+	}
+	shipped, err := repos.Shipments.ShippedQuantities(ctx, order.ID, domain.ShipmentStatusShipped, domain.ShipmentStatusDelivered)
+	if err != nil {
+		return err
+	}
+	delivered, err := repos.Shipments.ShippedQuantities(ctx, order.ID, domain.ShipmentStatusDelivered)
+	if err != nil {
+		return err
+	}
+
+	due, allShipped, allDelivered := 0, true, true
+	for _, item := range items {
+		quantity := item.Quantity - refunded[item.ID]
+		if quantity <= 0 {
+			continue
+		}
+		due++
+		if shipped[item.ID] < quantity {
+			allShipped = false
+		}
+		if delivered[item.ID] < quantity {
+			allDelivered = false
+		}
+	}
+	if due == 0 {
+		return nil
+	}
+
+	status := domain.OrderStatusProcessing
+	switch {
+	case allDelivered:
+		status = domain.OrderStatusDelivered
+		// Orders delivered in one go pass through shipped.
+		if !domain.CanTransitionOrder(order.Status, status) && domain.CanTransitionOrder(order.Status, domain.OrderStatusShipped) {
+			if err := transitionOrder(ctx, repos, order, domain.OrderStatusShipped, principal, reason); err != nil {
+				return err
+			}
+		}
+	case allShipped:
+		status = domain.OrderStatusShipped
+	}
+
+	if status == order.Status || !domain.CanTransitionOrder(order.Status, status) {
+		return nil
+	}
+	return transitionOrder(ctx, repos, order, status, principal, reason)
+}
+
+// cancelShipments cancels the shipments of order orderID that have not left
+// yet. It fails with ErrInvalidState when units have already shipped.
+func cancelShipments(ctx context.Context, repos repository.Repositories, orderID uint) error {
+	shipments, err := repos.Shipments.GetByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	for _, shipment := range shipments {
+		if shipment.Status == domain.ShipmentStatusShipped || shipment.Status == domain.ShipmentStatusDelivered {
+			return domain.NewError(domain.ErrInvalidState, "order has shipped items").
+				WithDetail("shipment_id", shipment.ID)
+		}
+	}
+	for _, shipment := range shipments {
+		if shipment.Status != domain.ShipmentStatusPending {
+			continue
+		}
+		shipment.Status = domain.ShipmentStatusCancelled
+		if err := repos.Shipments.Update(ctx, shipment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"shop/internal/domain"
+)
+
+func TestShipmentsAdvanceOrder(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			f := newFixture(t, b, nil)
+			ctx, admin, orders, shipments := f.ctx, f.admin, f.orders, f.shipments
+
+			order := f.placeOrder(3)
+			paid, err := orders.UpdateOrderStatus(ctx, admin, order.ID, domain.OrderStatusPaid, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			itemID := paid.Items[0].ID
+
+			status := func(want string) {
+				t.Helper()
+				got, err := orders.GetOrder(ctx, admin, order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != want {
+					t.Errorf("order status = %q, want %q", got.Status, want)
+				}
+			}
+
+			first, err := shipments.CreateShipment(ctx, admin, order.ID, &domain.CreateShipmentRequest{
+				Carrier: "ups",
+				Status:  domain.ShipmentStatusShipped,
+				Items:   []domain.ShipmentItemRequest{{OrderItemID: itemID, Quantity: 2}},
+			})
+			if err != nil {
+				t.Fatalf("CreateShipment: %v", err)
+			}
+			status(domain.OrderStatusProcessing)
+
+			if _, err := shipments.CreateShipment(ctx, admin, order.ID, &domain.CreateShipmentRequest{
+				Carrier: "ups",
+				Items:   []domain.ShipmentItemRequest{{OrderItemID: itemID, Quantity: 2}},
+			}); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("shipping more than ordered: got %v, want ErrInvalidInput", err)
+			}
+
+			second, err := shipments.CreateShipment(ctx, admin, order.ID, &domain.CreateShipmentRequest{Carrier: "dhl", Status: domain.ShipmentStatusShipped})
+			if err != nil {
+				t.Fatalf("CreateShipment of the rest: %v", err)
+			}
+			if len(second.Items) != 1 || second.Items[0].Quantity != 1 {
+				t.Errorf("rest = %+v, want the 1 unit left", second.Items)
+			}
+			status(domain.OrderStatusShipped)
+
+			for _, shipment := range []*domain.Shipment{first, second} {
+				if _, err := shipments.UpdateShipment(ctx, admin, shipment.ID, &domain.UpdateShipmentRequest{Status: domain.ShipmentStatusDelivered}); err != nil {
+					t.Fatalf("UpdateShipment: %v", err)
+				}
+			}
+			status(domain.OrderStatusDelivered)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package usecase
 
 import (
+    "context"
     "errors"
     "shop/internal/domain"
     "shop/internal/repository"
@@ -16,11 +17,14 @@ func NewUserUsecase(userRepo repository.UserRepository) *UserUsecase {
     return &UserUsecase{userRepo: userRepo}
 }
 
-func (u *UserUsecase) Register(req *domain.UserRequest) (*domain.User, error) {
+func (u *UserUsecase) Register(ctx context.Context, req *domain.UserRequest) (*domain.User, error) {
     // Check if user already exists
-    _, err := u.userRepo.GetByEmail(req.Email)
+    _, err := u.userRepo.GetByEmail(ctx, req.Email)
     if err == nil {
-        return nil, errors.New("user already exists")
+        return nil, domain.NewError(domain.ErrConflict, "user already exists")
+    }
+    if !errors.Is(err, domain.ErrNotFound) {
+        return nil, err
     }
 
     // Hash password
@@ -33,24 +37,27 @@ func (u *UserUsecase) Register(req *domain.UserRequest) (*domain.User, error) {
         Username: req.Username,
         Email:    req.Email,
         Password: string(hashedPassword),
-        Role:     "user",
+        Role:     domain.RoleUser,
     }
 
-    if err := u.userRepo.Create(user); err != nil {
+    if err := u.userRepo.Create(ctx, user); err != nil {
         return nil, err
     }
 
     return user, nil
 }
 
-func (u *UserUsecase) Login(req *domain.LoginRequest) (*domain.LoginResponse, error) {
-    user, err := u.userRepo.GetByEmail(req.Email)
+func (u *UserUsecase) Login(ctx context.Context, req *domain.LoginRequest) (*domain.LoginResponse, error) {
+    user, err := u.userRepo.GetByEmail(ctx, req.Email)
+    if errors.Is(err, domain.ErrNotFound) {
+        return nil, domain.NewError(domain.ErrUnauthorized, "invalid credentials")
+    }
     if err != nil {
-        return nil, errors.New("invalid credentials")
+        return nil, err
     }
 
     if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-        return nil, errors.New("invalid credentials")
+        return nil, domain.NewError(domain.ErrUnauthorized, "invalid credentials")
     }
 
     token, err := jwt.GenerateToken(user.ID, user.Email, user.Role)
@@ -64,6 +71,10 @@ func (u *UserUsecase) Login(req *domain.LoginRequest) (*domain.LoginResponse, er
     }, nil
 }
 
-func (u *UserUsecase) GetProfile(userID uint) (*domain.User, error) {
-    return u.userRepo.GetByID(userID)
+func (u *UserUsecase) GetProfile(ctx context.Context, userID uint) (*domain.User, error) {
+    user, err := u.userRepo.GetByID(ctx, userID)
+    if err != nil {
+        return nil, notFound(err, "user")
+    }
+    return user, nil
 }
\ No newline at end of file
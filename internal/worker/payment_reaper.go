@@ -0,0 +1,106 @@
+// Package worker runs background jobs next to the HTTP server.
+package worker
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"shop/internal/domain"
+	"shop/internal/usecase"
+	"shop/pkg/config"
+	"time"
+)
+
+// reaperMetrics is published through expvar as "payment_reaper".
+var reaperMetrics = expvar.NewMap("payment_reaper")
+
+// PaymentReaper periodically cancels payments that stayed pending longer
+// than the configured age, which releases their stock and unlocks the cart.
+//
+// Several instances may run at once: each payment leaves the pending state
+// through a conditional update, so only one instance cancels it and the
+// others count it as skipped.
+type PaymentReaper struct {
+	payments *usecase.PaymentUsecase
+	cfg      config.ReaperConfig
+	logger   *slog.Logger
+}
+
+func NewPaymentReaper(payments *usecase.PaymentUsecase, cfg config.ReaperConfig, logger *slog.Logger) *PaymentReaper {
+	return &PaymentReaper{
+		payments: payments,
+		cfg:      cfg,
+		logger:   logger.With("worker", "payment_reaper"),
+	}
+}
+
+// Run reaps once per interval until ctx is done. It returns once the batch
+// in progress has stopped.
+func (r *PaymentReaper) Run(ctx context.Context) {
+	r.logger.Info("started", "interval", r.cfg.Interval.String(), "max_age", r.cfg.MaxAge.String(), "batch_size", r.cfg.BatchSize)
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce cancels one batch of stale payments and returns how many it
+// cancelled. When ctx is done the batch stops before the next payment; the
+// cancellation in progress is finished so that it does not leave the payment
+// cancelled with its stock still held.
+func (r *PaymentReaper) RunOnce(ctx context.Context) int {
+	work, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.Interval)
+	defer cancel()
+
+	reaperMetrics.Add("runs", 1)
+	reaperMetrics.Set("last_run_unix", unixTime(time.Now()))
+
+	stale, err := r.payments.StalePendingPayments(work, r.cfg.MaxAge, r.cfg.BatchSize)
+	if err != nil {
+		reaperMetrics.Add("errors", 1)
+		r.logger.Error("listing stale payments failed", "error", err)
+		return 0
+	}
+
+	var expired int
+	for _, payment := range stale {
+		if ctx.Err() != nil {
+			break
+		}
+		log := r.logger.With("payment_id", payment.ID, "user_id", payment.UserID, "age", time.Since(payment.CreatedAt).Round(time.Second).String())
+
+		err := r.payments.ExpirePayment(work, payment)
+		switch {
+		case err == nil:
+			expired++
+			reaperMetrics.Add("expired", 1)
+			log.Info("cancelled abandoned payment")
+		case errors.Is(err, domain.ErrInvalidState):
+			// Settled by a callback, the user or another instance meanwhile
+			reaperMetrics.Add("skipped", 1)
+			log.Info("payment no longer pending")
+		default:
+			reaperMetrics.Add("errors", 1)
+			log.Error("cancelling payment failed", "error", err)
+		}
+	}
+
+	return expired
+}
+
+func unixTime(t time.Time) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(t.Unix())
+	return v
+}
@@ -0,0 +1,122 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"shop/internal/domain"
+	"shop/internal/gateway/simulator"
+	"shop/internal/repository"
+	"shop/internal/repository/memory"
+	"shop/internal/usecase"
+	"shop/internal/worker"
+	"shop/pkg/config"
+)
+
+// racingPayments lists the settled payment as stale, as if it settled right
+// after the reaper listed it, and fails to change the status of broken.
+type racingPayments struct {
+	repository.PaymentRepository
+	settled, broken uint
+}
+
+func (r racingPayments) GetPendingBefore(ctx context.Context, cutoff time.Time, limit int) ([]*domain.Payment, error) {
+	stale, err := r.PaymentRepository.GetPendingBefore(ctx, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	settled, err := r.PaymentRepository.GetByID(ctx, r.settled)
+	if err != nil {
+		return nil, err
+	}
+	return append(stale, settled), nil
+}
+
+func (r racingPayments) TransitionStatus(ctx context.Context, id uint, from, to string) (int64, error) {
+	if id == r.broken {
+		return 0, errors.New("database is gone")
+	}
+	return r.PaymentRepository.TransitionStatus(ctx, id, from, to)
+}
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+
+	user := &domain.User{Username: "buyer", Email: "buyer@example.com", Role: domain.RoleUser}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	payment := func(status string) *domain.Payment {
+		p := &domain.Payment{UserID: user.ID, Amount: 10, Status: status, PaymentMethod: "card"}
+		if err := repos.Payments.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	abandoned := payment(domain.PaymentStatusPending)
+	broken := payment(domain.PaymentStatusPending)
+	settled := payment(domain.PaymentStatusCompleted)
+	if err := repos.Users.LockCart(ctx, user.ID, abandoned.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	payments := racingPayments{repos.Payments, settled.ID, broken.ID}
+	reservations := usecase.NewReservationService(repos.Reservations, time.Minute)
+	paymentUsecase := usecase.NewPaymentUseCase(payments, repos.Carts, repos.Orders, repos.Products, repos.Users,
+		simulator.New(simulator.Config{}), memory.NewUnitOfWork(store), time.Hour, reservations)
+	reaper := worker.NewPaymentReaper(paymentUsecase, config.ReaperConfig{Interval: time.Minute, MaxAge: time.Millisecond, BatchSize: 10},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	metrics := expvar.Get("payment_reaper").(*expvar.Map)
+	counter := func(name string) int64 {
+		if v, ok := metrics.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	before := map[string]int64{"expired": counter("expired"), "skipped": counter("skipped"), "errors": counter("errors")}
+
+	// Stopping the reaper before the batch starts leaves every payment alone.
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	if n := reaper.RunOnce(stopped); n != 0 {
+		t.Errorf("RunOnce after stop = %d, want 0", n)
+	}
+
+	if n := reaper.RunOnce(ctx); n != 1 {
+		t.Errorf("RunOnce = %d, want 1", n)
+	}
+	for name, want := range map[string]int64{"expired": 1, "skipped": 1, "errors": 1} {
+		if got := counter(name) - before[name]; got != want {
+			t.Errorf("%s counter went up by %d, want %d", name, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		payment *domain.Payment
+		want    string
+	}{
+		{abandoned, domain.PaymentStatusCancelled},
+		{broken, domain.PaymentStatusPending},
+		{settled, domain.PaymentStatusCompleted},
+	} {
+		got, err := repos.Payments.GetByID(ctx, tt.payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("payment %d status = %q, want %q", got.ID, got.Status, tt.want)
+		}
+	}
+	if got, err := repos.Users.GetByID(ctx, user.ID); err != nil || got.CartLocked(time.Now()) {
+		t.Errorf("cart still locked after reaping its payment (err %v)", err)
+	}
+}
@@ -0,0 +1,27 @@
+// Package migrations embeds the versioned SQL schema. Files are named
+// NNNN_description.up.sql and NNNN_description.down.sql and are applied in
+// version order by database.Migrator.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+var (
+	// MySQL holds the migrations for the MySQL schema.
+	MySQL = mustSub("mysql")
+	// SQLite holds the same schema in the SQLite dialect.
+	SQLite = mustSub("sqlite")
+)
+
+func mustSub(dir string) fs.FS {
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN lock_cart, DROP COLUMN total_cart;
@@ -0,0 +1,23 @@
+-- Databases created before versioned migrations may already have these
+-- columns, so each one is only added when it is missing.
+SET @stmt = (
+    SELECT IF(COUNT(*) = 0,
+        'ALTER TABLE users ADD COLUMN total_cart DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER role',
+        'DO 0')
+    FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'total_cart'
+);
+PREPARE add_column FROM @stmt;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
+
+SET @stmt = (
+    SELECT IF(COUNT(*) = 0,
+        'ALTER TABLE users ADD COLUMN lock_cart BOOL NOT NULL DEFAULT 0 AFTER total_cart',
+        'DO 0')
+    FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'lock_cart'
+);
+PREPARE add_column FROM @stmt;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
@@ -0,0 +1,4 @@
+UPDATE payments SET status = 'failed' WHERE status = 'needs_refund';
+
+ALTER TABLE payments
+    MODIFY COLUMN status ENUM('pending', 'completed', 'failed', 'cancelled') DEFAULT 'pending';
@@ -0,0 +1,2 @@
+ALTER TABLE payments
+    MODIFY COLUMN status ENUM('pending', 'completed', 'failed', 'cancelled', 'needs_refund') DEFAULT 'pending';
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN lock_payment_id, DROP COLUMN lock_expires_at;
@@ -0,0 +1,5 @@
+-- The checkout lock records the payment holding it and when it lapses, so it
+-- can be taken and released with compare-and-set updates.
+ALTER TABLE users
+    ADD COLUMN lock_payment_id INT NULL AFTER lock_cart,
+    ADD COLUMN lock_expires_at TIMESTAMP NULL AFTER lock_payment_id;
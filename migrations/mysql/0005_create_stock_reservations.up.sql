@@ -0,0 +1,14 @@
+-- Units held for pending payments. A hold stops counting once expires_at has
+-- passed, and its rows are deleted when the payment settles or is cancelled.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    payment_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (payment_id) REFERENCES payments(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE KEY unique_payment_product (payment_id, product_id),
+    INDEX idx_product_expires (product_id, expires_at)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+
+UPDATE payments SET status = 'completed' WHERE status IN ('partially_refunded', 'refunded');
+
+ALTER TABLE payments
+    MODIFY COLUMN status ENUM('pending', 'completed', 'failed', 'cancelled', 'needs_refund') DEFAULT 'pending';
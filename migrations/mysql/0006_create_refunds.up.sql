@@ -0,0 +1,35 @@
+ALTER TABLE payments
+    MODIFY COLUMN status ENUM('pending', 'completed', 'failed', 'cancelled', 'needs_refund', 'partially_refunded', 'refunded') DEFAULT 'pending';
+
+-- Money given back for a payment. order_id is set when the refund covers
+-- items of an order, which are listed in refund_items.
+CREATE TABLE IF NOT EXISTS refunds (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    payment_id INT NOT NULL,
+    order_id INT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    restock BOOLEAN NOT NULL DEFAULT FALSE,
+    gateway_refund_id VARCHAR(255) NOT NULL DEFAULT '',
+    gateway_response TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (payment_id) REFERENCES payments(id),
+    FOREIGN KEY (order_id) REFERENCES orders(id),
+    INDEX idx_payment_id (payment_id),
+    INDEX idx_order_id (order_id)
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    refund_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE KEY unique_refund_order_item (refund_id, order_item_id)
+);
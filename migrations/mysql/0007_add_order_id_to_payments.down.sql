@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP FOREIGN KEY fk_payments_order;
+ALTER TABLE payments DROP INDEX idx_order_id;
+ALTER TABLE payments DROP COLUMN order_id;
@@ -0,0 +1,6 @@
+-- The order a payment paid for. It is set when the order is created from the
+-- cart, so pending, failed and cancelled payments have none.
+ALTER TABLE payments
+    ADD COLUMN order_id INT NULL AFTER user_id,
+    ADD INDEX idx_order_id (order_id),
+    ADD CONSTRAINT fk_payments_order FOREIGN KEY (order_id) REFERENCES orders(id);
@@ -0,0 +1,21 @@
+-- Orders created from payments used to be 'confirmed'; the lifecycle calls
+-- that status 'paid'.
+UPDATE orders SET status = 'paid' WHERE status = 'confirmed';
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    from_status VARCHAR(50) NOT NULL DEFAULT '',
+    to_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(20) NOT NULL,
+    changed_by INT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_order_created (order_id, created_at)
+);
+
+-- Start the timeline of existing orders at their current status.
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, created_at)
+SELECT id, '', status, 'system', 'recorded before status history', created_at FROM orders;
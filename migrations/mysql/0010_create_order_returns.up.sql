@@ -0,0 +1,33 @@
+-- Customer returns of shipped units, listed per order item in
+-- order_return_items. refund_id points at the refund issued once the
+-- returned goods were accepted.
+CREATE TABLE IF NOT EXISTS order_returns (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    user_id INT NOT NULL,
+    status ENUM('requested', 'approved', 'rejected', 'received', 'accepted', 'refunded', 'cancelled') NOT NULL DEFAULT 'requested',
+    reason VARCHAR(255) NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    restocked BOOLEAN NOT NULL DEFAULT FALSE,
+    refund_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (refund_id) REFERENCES refunds(id),
+    INDEX idx_order_id (order_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_status (status)
+);
+
+CREATE TABLE IF NOT EXISTS order_return_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    return_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    FOREIGN KEY (return_id) REFERENCES order_returns(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE KEY unique_return_order_item (return_id, order_item_id)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE payments
+    DROP FOREIGN KEY fk_payments_shipping_address,
+    DROP FOREIGN KEY fk_payments_billing_address,
+    DROP COLUMN shipping_address_id,
+    DROP COLUMN billing_address_id;
+
+DROP TABLE IF EXISTS order_addresses;
+DROP TABLE IF EXISTS addresses;
@@ -0,0 +1,49 @@
+-- Customers' address books. Each customer has at most one default address
+-- of each kind, which the usecase keeps unique.
+CREATE TABLE IF NOT EXISTS addresses (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    label VARCHAR(50) NOT NULL DEFAULT '',
+    full_name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL,
+    country CHAR(2) NOT NULL,
+    default_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    default_billing BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_id (user_id)
+);
+
+-- Copies of the addresses an order was placed with. address_id points at
+-- the address book entry until that entry is deleted.
+CREATE TABLE IF NOT EXISTS order_addresses (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    kind ENUM('shipping', 'billing') NOT NULL,
+    address_id INT NULL,
+    full_name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL,
+    country CHAR(2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (address_id) REFERENCES addresses(id) ON DELETE SET NULL,
+    UNIQUE KEY unique_order_address_kind (order_id, kind)
+);
+
+-- Addresses chosen at checkout, copied into the order once it is paid.
+ALTER TABLE payments
+    ADD COLUMN shipping_address_id INT NULL AFTER order_id,
+    ADD COLUMN billing_address_id INT NULL AFTER shipping_address_id,
+    ADD CONSTRAINT fk_payments_shipping_address FOREIGN KEY (shipping_address_id) REFERENCES addresses(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_payments_billing_address FOREIGN KEY (billing_address_id) REFERENCES addresses(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE orders
+    DROP COLUMN tax,
+    DROP COLUMN discount,
+    DROP COLUMN discount_code,
+    DROP COLUMN shipping_cost,
+    DROP COLUMN shipping_method;
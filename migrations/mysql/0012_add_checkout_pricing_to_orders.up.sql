@@ -0,0 +1,8 @@
+-- How a checkout priced the order. total stays the amount charged; orders
+-- placed without a checkout keep zero and empty values.
+ALTER TABLE orders
+    ADD COLUMN shipping_method VARCHAR(50) NOT NULL DEFAULT '' AFTER status,
+    ADD COLUMN shipping_cost DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER shipping_method,
+    ADD COLUMN discount_code VARCHAR(50) NOT NULL DEFAULT '' AFTER shipping_cost,
+    ADD COLUMN discount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER discount_code,
+    ADD COLUMN tax DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER discount;
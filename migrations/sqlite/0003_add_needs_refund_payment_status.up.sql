@@ -0,0 +1 @@
+-- Already part of 0001 in SQLite; kept so versions line up with MySQL.
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN lock_expires_at;
+ALTER TABLE users DROP COLUMN lock_payment_id;
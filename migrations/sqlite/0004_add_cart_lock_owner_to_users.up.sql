@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN lock_payment_id INTEGER NULL;
+ALTER TABLE users ADD COLUMN lock_expires_at TIMESTAMP NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_payment_product UNIQUE (payment_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_product_expires ON stock_reservations (product_id, expires_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+
+UPDATE payments SET status = 'completed' WHERE status IN ('partially_refunded', 'refunded');
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS refunds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_id INTEGER NOT NULL REFERENCES payments(id),
+    order_id INTEGER NULL REFERENCES orders(id),
+    amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    restock BOOLEAN NOT NULL DEFAULT FALSE,
+    gateway_refund_id VARCHAR(255) NOT NULL DEFAULT '',
+    gateway_response TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds (payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds (order_id);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    refund_id INTEGER NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    order_item_id INTEGER NOT NULL REFERENCES order_items(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    CONSTRAINT unique_refund_order_item UNIQUE (refund_id, order_item_id)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payments_order_id;
+ALTER TABLE payments DROP COLUMN order_id;
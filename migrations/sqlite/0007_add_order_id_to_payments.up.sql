@@ -0,0 +1,5 @@
+-- No REFERENCES clause: SQLite cannot drop a column that has one, which the
+-- down migration needs.
+ALTER TABLE payments ADD COLUMN order_id INTEGER NULL;
+
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_status_history;
+
+UPDATE orders SET status = 'confirmed' WHERE status = 'paid';
@@ -0,0 +1,20 @@
+-- Orders created from payments used to be 'confirmed'; the lifecycle calls
+-- that status 'paid'.
+UPDATE orders SET status = 'paid' WHERE status = 'confirmed';
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(50) NOT NULL DEFAULT '',
+    to_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(20) NOT NULL,
+    changed_by INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_created ON order_status_history (order_id, created_at);
+
+-- Start the timeline of existing orders at their current status.
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, created_at)
+SELECT id, '', status, 'system', 'recorded before status history', created_at FROM orders;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS order_returns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'requested',
+    reason VARCHAR(255) NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    restocked BOOLEAN NOT NULL DEFAULT FALSE,
+    refund_id INTEGER NULL REFERENCES refunds(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_returns_order_id ON order_returns (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_returns_user_id ON order_returns (user_id);
+CREATE INDEX IF NOT EXISTS idx_order_returns_status ON order_returns (status);
+
+CREATE TABLE IF NOT EXISTS order_return_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    return_id INTEGER NOT NULL REFERENCES order_returns(id) ON DELETE CASCADE,
+    order_item_id INTEGER NOT NULL REFERENCES order_items(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    CONSTRAINT unique_return_order_item UNIQUE (return_id, order_item_id)
+);
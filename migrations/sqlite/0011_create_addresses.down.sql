@@ -0,0 +1,5 @@
+ALTER TABLE payments DROP COLUMN billing_address_id;
+ALTER TABLE payments DROP COLUMN shipping_address_id;
+
+DROP TABLE IF EXISTS order_addresses;
+DROP TABLE IF EXISTS addresses;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    label VARCHAR(50) NOT NULL DEFAULT '',
+    full_name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL,
+    country CHAR(2) NOT NULL,
+    default_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    default_billing BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses (user_id);
+
+CREATE TABLE IF NOT EXISTS order_addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    address_id INTEGER NULL REFERENCES addresses(id) ON DELETE SET NULL,
+    full_name VARCHAR(100) NOT NULL,
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL,
+    country CHAR(2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_order_address_kind UNIQUE (order_id, kind)
+);
+
+-- Addresses chosen at checkout, copied into the order once it is paid.
+ALTER TABLE payments ADD COLUMN shipping_address_id INTEGER NULL;
+ALTER TABLE payments ADD COLUMN billing_address_id INTEGER NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP COLUMN tax;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN discount_code;
+ALTER TABLE orders DROP COLUMN shipping_cost;
+ALTER TABLE orders DROP COLUMN shipping_method;
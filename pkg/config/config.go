@@ -0,0 +1,488 @@
+// Package config loads application settings from profile defaults, an
+// optional YAML file and environment variables, in that order of precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
+// Storage backends.
+const (
+	StorageMySQL  = "mysql"
+	StorageSQLite = "sqlite"
+	StorageMemory = "memory"
+)
+
+type Config struct {
+	Env      string         `yaml:"env"`
+	Storage  string         `yaml:"storage"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	SQLite   SQLiteConfig   `yaml:"sqlite"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Payment  PaymentConfig  `yaml:"payment"`
+	Checkout CheckoutConfig `yaml:"checkout"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type DatabaseConfig struct {
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+type SQLiteConfig struct {
+	Path string `yaml:"path"`
+}
+
+type JWTConfig struct {
+	Secret string        `yaml:"secret"`
+	TTL    time.Duration `yaml:"ttl"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type PaymentConfig struct {
+	Provider string `yaml:"provider"`
+	// LockTTL is how long a pending payment holds the checkout lock on a cart.
+	LockTTL time.Duration `yaml:"lock_ttl"`
+	// ReservationTTL is how long a pending payment holds its stock.
+	ReservationTTL time.Duration   `yaml:"reservation_ttl"`
+	Simulator      SimulatorConfig `yaml:"simulator"`
+	Reaper         ReaperConfig    `yaml:"reaper"`
+}
+
+// ReaperConfig controls the worker that cancels abandoned pending payments.
+type ReaperConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval"`
+	MaxAge    time.Duration `yaml:"max_age"`
+	BatchSize int           `yaml:"batch_size"`
+}
+
+// CheckoutConfig prices what a checkout adds to the cart.
+type CheckoutConfig struct {
+	// TaxRate is charged on the discounted subtotal plus shipping, as a
+	// fraction: 0.2 is 20%.
+	TaxRate         float64                `yaml:"tax_rate"`
+	ShippingMethods []ShippingMethodConfig `yaml:"shipping_methods"`
+	Discounts       []DiscountConfig       `yaml:"discounts"`
+}
+
+type ShippingMethodConfig struct {
+	Code  string  `yaml:"code"`
+	Name  string  `yaml:"name"`
+	Price float64 `yaml:"price"`
+	// FreeOver waives the price from this discounted subtotal on; 0 never
+	// waives it.
+	FreeOver float64 `yaml:"free_over"`
+}
+
+// DiscountConfig is a discount code. It takes Percent off the subtotal, or
+// Amount when Percent is 0.
+type DiscountConfig struct {
+	Code        string  `yaml:"code"`
+	Percent     float64 `yaml:"percent"`
+	Amount      float64 `yaml:"amount"`
+	MinSubtotal float64 `yaml:"min_subtotal"`
+}
+
+type SimulatorConfig struct {
+	Outcome     string        `yaml:"outcome"`
+	Secret      string        `yaml:"secret"`
+	CallbackURL string        `yaml:"callback_url"`
+	Delay       time.Duration `yaml:"delay"`
+}
+
+// DSN builds the MySQL data source name.
+func (d DatabaseConfig) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = d.User
+	cfg.Passwd = d.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	cfg.DBName = d.Name
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	return cfg.FormatDSN()
+}
+
+// Load builds the configuration for the profile named by APP_ENV (dev when
+// unset). Values from the YAML file at path, or at CONFIG_FILE when path is
+// empty, override the profile defaults, and environment variables override
+// both. The result is validated before it is returned.
+func Load(path string) (*Config, error) {
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = ProfileDev
+	}
+
+	cfg, err := defaults(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func defaults(env string) (*Config, error) {
+	cfg := &Config{
+		Env:     env,
+		Storage: StorageMySQL,
+		Server:  ServerConfig{Addr: ":8080"},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            3306,
+			User:            "root",
+			Name:            "shop_db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		SQLite: SQLiteConfig{Path: "shop.db"},
+		JWT:    JWTConfig{TTL: 24 * time.Hour},
+		Payment: PaymentConfig{
+			Provider:       "simulator",
+			LockTTL:        15 * time.Minute,
+			ReservationTTL: 15 * time.Minute,
+			Simulator: SimulatorConfig{
+				Outcome:     "success",
+				CallbackURL: "http://localhost:8080/api/v1/payments/callback",
+				Delay:       2 * time.Second,
+			},
+			Reaper: ReaperConfig{
+				Enabled:   true,
+				Interval:  time.Minute,
+				MaxAge:    10 * time.Minute,
+				BatchSize: 100,
+			},
+		},
+		Checkout: CheckoutConfig{
+			ShippingMethods: []ShippingMethodConfig{
+				{Code: "standard", Name: "Standard delivery", Price: 4.99, FreeOver: 50},
+				{Code: "express", Name: "Express delivery", Price: 14.99},
+			},
+		},
+	}
+
+	switch env {
+	case ProfileDev:
+		cfg.JWT.Secret = "dev-jwt-secret-change-me"
+		cfg.CORS.AllowedOrigins = []string{"http://localhost:3000"}
+		cfg.Payment.Simulator.Secret = "dev-callback-secret"
+	case ProfileTest:
+		cfg.Database.Name = "shop_test"
+		cfg.Database.MaxOpenConns = 5
+		cfg.Database.MaxIdleConns = 5
+		cfg.JWT.Secret = "test-jwt-secret"
+		cfg.JWT.TTL = time.Hour
+		cfg.Payment.Simulator.Secret = "test-callback-secret"
+		cfg.Payment.Simulator.Delay = 0
+		cfg.Payment.Reaper.Enabled = false
+	case ProfileProd:
+		// Secrets, credentials and origins must be supplied explicitly.
+		cfg.Payment.Simulator.CallbackURL = ""
+	default:
+		return nil, fmt.Errorf("unknown APP_ENV %q: use dev, test or prod", env)
+	}
+
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	env := cfg.Env
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	if cfg.Env != env {
+		return fmt.Errorf("config file %s is for profile %q but APP_ENV selects %q", path, cfg.Env, env)
+	}
+
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	var errs []error
+
+	setString := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	setInt := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	setBool := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = b
+		}
+	}
+	setFloat := func(key string, dst *float64) {
+		if v, ok := os.LookupEnv(key); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = f
+		}
+	}
+	setDuration := func(key string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	if port, ok := os.LookupEnv("PORT"); ok {
+		cfg.Server.Addr = ":" + port
+	}
+	setString("SERVER_ADDR", &cfg.Server.Addr)
+
+	setString("STORAGE", &cfg.Storage)
+	setString("SQLITE_PATH", &cfg.SQLite.Path)
+
+	setString("DB_HOST", &cfg.Database.Host)
+	setInt("DB_PORT", &cfg.Database.Port)
+	setString("DB_USER", &cfg.Database.User)
+	setString("DB_PASSWORD", &cfg.Database.Password)
+	setString("DB_NAME", &cfg.Database.Name)
+	setInt("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	setInt("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	setDuration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+
+	setString("JWT_SECRET", &cfg.JWT.Secret)
+	setDuration("JWT_TTL", &cfg.JWT.TTL)
+
+	if v, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok {
+		cfg.CORS.AllowedOrigins = splitList(v)
+	}
+
+	setString("PAYMENT_PROVIDER", &cfg.Payment.Provider)
+	setDuration("PAYMENT_LOCK_TTL", &cfg.Payment.LockTTL)
+	setDuration("PAYMENT_RESERVATION_TTL", &cfg.Payment.ReservationTTL)
+	setString("PAYMENT_SIMULATOR_OUTCOME", &cfg.Payment.Simulator.Outcome)
+	setString("PAYMENT_CALLBACK_SECRET", &cfg.Payment.Simulator.Secret)
+	setString("PAYMENT_CALLBACK_URL", &cfg.Payment.Simulator.CallbackURL)
+	setDuration("PAYMENT_SIMULATOR_DELAY", &cfg.Payment.Simulator.Delay)
+	setBool("PAYMENT_REAPER_ENABLED", &cfg.Payment.Reaper.Enabled)
+	setDuration("PAYMENT_REAPER_INTERVAL", &cfg.Payment.Reaper.Interval)
+	setDuration("PAYMENT_REAPER_MAX_AGE", &cfg.Payment.Reaper.MaxAge)
+	setInt("PAYMENT_REAPER_BATCH_SIZE", &cfg.Payment.Reaper.BatchSize)
+
+	setFloat("CHECKOUT_TAX_RATE", &cfg.Checkout.TaxRate)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Addr == "" {
+		add("server.addr is required")
+	}
+
+	switch c.Storage {
+	case StorageMySQL:
+		if c.Database.Host == "" || c.Database.Name == "" || c.Database.User == "" {
+			add("database host, user and name are required")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			add("database.port %d is out of range", c.Database.Port)
+		}
+		if c.Database.MaxOpenConns < 1 {
+			add("database.max_open_conns must be at least 1")
+		}
+		if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+			add("database.max_idle_conns must be between 0 and max_open_conns")
+		}
+	case StorageSQLite:
+		if c.SQLite.Path == "" {
+			add("sqlite.path is required")
+		}
+	case StorageMemory:
+	default:
+		add("unknown storage %q: use mysql, sqlite or memory", c.Storage)
+	}
+
+	if c.JWT.Secret == "" {
+		add("jwt.secret is required")
+	}
+	if c.JWT.TTL <= 0 {
+		add("jwt.ttl must be positive")
+	}
+
+	if c.Payment.LockTTL <= 0 {
+		add("payment.lock_ttl must be positive")
+	}
+	if c.Payment.ReservationTTL <= 0 {
+		add("payment.reservation_ttl must be positive")
+	}
+
+	if c.Payment.Reaper.Enabled {
+		if c.Payment.Reaper.Interval <= 0 || c.Payment.Reaper.MaxAge <= 0 {
+			add("payment.reaper interval and max_age must be positive")
+		}
+		if c.Payment.Reaper.BatchSize < 1 {
+			add("payment.reaper.batch_size must be at least 1")
+		}
+		// A payment younger than max_age is left alone, so its lock and
+		// reservation must not expire before the reaper may cancel it.
+		if limit := min(c.Payment.LockTTL, c.Payment.ReservationTTL); c.Payment.Reaper.MaxAge > limit {
+			add("payment.reaper.max_age %s must not exceed lock_ttl and reservation_ttl (%s)", c.Payment.Reaper.MaxAge, limit)
+		}
+	}
+
+	switch c.Payment.Provider {
+	case "simulator":
+		switch c.Payment.Simulator.Outcome {
+		case "success", "decline", "timeout":
+		default:
+			add("payment.simulator.outcome %q must be success, decline or timeout", c.Payment.Simulator.Outcome)
+		}
+		if c.Payment.Simulator.Secret == "" {
+			add("payment.simulator.secret is required")
+		}
+	default:
+		add("unknown payment.provider %q", c.Payment.Provider)
+	}
+
+	c.Checkout.validate(add)
+
+	if c.Env == ProfileProd {
+		if len(c.JWT.Secret) < 32 {
+			add("jwt.secret must be at least 32 characters in prod")
+		}
+		if c.Storage == StorageMySQL && c.Database.Password == "" {
+			add("database.password is required in prod")
+		}
+		if len(c.CORS.AllowedOrigins) == 0 {
+			add("cors.allowed_origins is required in prod")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c CheckoutConfig) validate(add func(format string, args ...interface{})) {
+	if c.TaxRate < 0 || c.TaxRate >= 1 {
+		add("checkout.tax_rate %v must be at least 0 and below 1", c.TaxRate)
+	}
+
+	if len(c.ShippingMethods) == 0 {
+		add("checkout.shipping_methods needs at least one method")
+	}
+	seen := make(map[string]bool)
+	for _, m := range c.ShippingMethods {
+		switch {
+		case m.Code == "":
+			add("checkout.shipping_methods: code is required")
+		case seen[m.Code]:
+			add("checkout.shipping_methods: code %q is listed twice", m.Code)
+		}
+		seen[m.Code] = true
+		if m.Price < 0 || m.FreeOver < 0 {
+			add("checkout.shipping_methods: %q has a negative price or free_over", m.Code)
+		}
+	}
+
+	seen = make(map[string]bool)
+	for _, d := range c.Discounts {
+		code := strings.ToUpper(d.Code)
+		switch {
+		case code == "":
+			add("checkout.discounts: code is required")
+		case seen[code]:
+			add("checkout.discounts: code %q is listed twice", d.Code)
+		}
+		seen[code] = true
+		if (d.Percent > 0) == (d.Amount > 0) || d.Percent < 0 || d.Percent > 100 || d.Amount < 0 {
+			add("checkout.discounts: %q needs either a percent up to 100 or an amount", d.Code)
+		}
+		if d.MinSubtotal < 0 {
+			add("checkout.discounts: %q has a negative min_subtotal", d.Code)
+		}
+	}
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
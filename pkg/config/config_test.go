@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shop/pkg/config"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	tests := []struct {
+		env   string
+		check func(t *testing.T, cfg *config.Config)
+	}{
+		{"", func(t *testing.T, cfg *config.Config) {
+			if cfg.Env != config.ProfileDev || !cfg.Payment.Reaper.Enabled || cfg.Payment.Simulator.Delay != 2*time.Second {
+				t.Errorf("config = %+v, want the dev profile with the reaper on", cfg)
+			}
+		}},
+		{config.ProfileTest, func(t *testing.T, cfg *config.Config) {
+			if cfg.Database.Name != "shop_test" || cfg.Payment.Reaper.Enabled || cfg.Payment.Simulator.Delay != 0 {
+				t.Errorf("config = %+v, want the test database, no reaper and no simulator delay", cfg)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run("APP_ENV="+tt.env, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.env)
+			t.Setenv("CONFIG_FILE", "")
+			cfg, err := config.Load("")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+
+	t.Run("prod needs secrets", func(t *testing.T) {
+		t.Setenv("APP_ENV", config.ProfileProd)
+		t.Setenv("CONFIG_FILE", "")
+		if _, err := config.Load(""); err == nil {
+			t.Fatal("Load succeeded without secrets")
+		}
+
+		t.Setenv("JWT_SECRET", strings.Repeat("s", 32))
+		t.Setenv("DB_PASSWORD", "password")
+		t.Setenv("CORS_ALLOWED_ORIGINS", "https://shop.example.com, https://admin.example.com")
+		t.Setenv("PAYMENT_CALLBACK_SECRET", "callback-secret")
+		cfg, err := config.Load("")
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if len(cfg.CORS.AllowedOrigins) != 2 || cfg.Payment.Simulator.CallbackURL != "" {
+			t.Errorf("config = %+v, want two origins and no callback URL", cfg)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		t.Setenv("APP_ENV", "staging")
+		if _, err := config.Load(""); err == nil {
+			t.Fatal("Load succeeded for an unknown profile")
+		}
+	})
+}
+
+func TestLoadOverrides(t *testing.T) {
+	t.Setenv("APP_ENV", config.ProfileTest)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "env: test\nstorage: sqlite\nserver:\n  addr: \":9000\"\ncheckout:\n  tax_rate: 0.2\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Storage != config.StorageSQLite || cfg.Server.Addr != ":9000" || cfg.Checkout.TaxRate != 0.2 {
+		t.Errorf("config = %+v, want the values from the file", cfg)
+	}
+	if len(cfg.Checkout.ShippingMethods) == 0 {
+		t.Error("the file dropped the default shipping methods")
+	}
+
+	// The environment wins over the file.
+	t.Setenv("PORT", "9100")
+	t.Setenv("CHECKOUT_TAX_RATE", "0.1")
+	t.Setenv("PAYMENT_REAPER_ENABLED", "true")
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Addr != ":9100" || cfg.Checkout.TaxRate != 0.1 || !cfg.Payment.Reaper.Enabled {
+		t.Errorf("config = %+v, want the values from the environment", cfg)
+	}
+
+	t.Run("malformed variable", func(t *testing.T) {
+		t.Setenv("DB_PORT", "3306a")
+		if _, err := config.Load(path); err == nil || !strings.Contains(err.Error(), "DB_PORT") {
+			t.Errorf("Load: err = %v, want it to name DB_PORT", err)
+		}
+	})
+
+	t.Run("file for another profile", func(t *testing.T) {
+		t.Setenv("APP_ENV", config.ProfileDev)
+		if _, err := config.Load(path); err == nil {
+			t.Error("Load accepted a file for the test profile under dev")
+		}
+	})
+
+	t.Run("example file", func(t *testing.T) {
+		t.Setenv("APP_ENV", config.ProfileDev)
+		t.Setenv("PORT", "")
+		os.Unsetenv("PORT")
+		if _, err := config.Load(filepath.Join("..", "..", "config", "config.example.yaml")); err != nil {
+			t.Errorf("Load: %v", err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("APP_ENV", config.ProfileTest)
+	t.Setenv("CONFIG_FILE", "")
+	valid, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   string
+	}{
+		{"valid", func(cfg *config.Config) {}, ""},
+		{"no server address", func(cfg *config.Config) { cfg.Server.Addr = "" }, "server.addr"},
+		{"unknown storage", func(cfg *config.Config) { cfg.Storage = "postgres" }, "unknown storage"},
+		{"sqlite without path", func(cfg *config.Config) { cfg.Storage, cfg.SQLite.Path = config.StorageSQLite, "" }, "sqlite.path"},
+		{"memory needs no database", func(cfg *config.Config) { cfg.Storage, cfg.Database = config.StorageMemory, config.DatabaseConfig{} }, ""},
+		{"port out of range", func(cfg *config.Config) { cfg.Database.Port = 70000 }, "database.port"},
+		{"more idle than open connections", func(cfg *config.Config) { cfg.Database.MaxIdleConns = 10 }, "max_idle_conns"},
+		{"no jwt secret", func(cfg *config.Config) { cfg.JWT.Secret = "" }, "jwt.secret"},
+		{"no lock ttl", func(cfg *config.Config) { cfg.Payment.LockTTL = 0 }, "lock_ttl"},
+		{"reaper without batch", func(cfg *config.Config) { cfg.Payment.Reaper.Enabled, cfg.Payment.Reaper.BatchSize = true, 0 }, "batch_size"},
+		{"reaper outliving the reservation", func(cfg *config.Config) {
+			cfg.Payment.Reaper.Enabled, cfg.Payment.Reaper.MaxAge = true, cfg.Payment.ReservationTTL+time.Minute
+		}, "max_age"},
+		{"disabled reaper is not checked", func(cfg *config.Config) { cfg.Payment.Reaper.BatchSize = 0 }, ""},
+		{"unknown outcome", func(cfg *config.Config) { cfg.Payment.Simulator.Outcome = "maybe" }, "outcome"},
+		{"unknown provider", func(cfg *config.Config) { cfg.Payment.Provider = "stripe" }, "payment.provider"},
+		{"tax rate of 100%", func(cfg *config.Config) { cfg.Checkout.TaxRate = 1 }, "tax_rate"},
+		{"no shipping methods", func(cfg *config.Config) { cfg.Checkout.ShippingMethods = nil }, "shipping_methods"},
+		{"duplicate shipping method", func(cfg *config.Config) {
+			cfg.Checkout.ShippingMethods = append(cfg.Checkout.ShippingMethods, cfg.Checkout.ShippingMethods[0])
+		}, "listed twice"},
+		{"duplicate discount code", func(cfg *config.Config) {
+			cfg.Checkout.Discounts = []config.DiscountConfig{{Code: "TEN", Percent: 10}, {Code: "ten", Amount: 10}}
+		}, "listed twice"},
+		{"discount with percent and amount", func(cfg *config.Config) {
+			cfg.Checkout.Discounts = []config.DiscountConfig{{Code: "BOTH", Percent: 10, Amount: 5}}
+		}, "either a percent"},
+		{"short prod secret", func(cfg *config.Config) {
+			cfg.Env, cfg.Database.Password, cfg.CORS.AllowedOrigins = config.ProfileProd, "password", []string{"https://shop.example.com"}
+		}, "at least 32 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *valid
+			cfg.Checkout.ShippingMethods = append([]config.ShippingMethodConfig(nil), valid.Checkout.ShippingMethods...)
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate: err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
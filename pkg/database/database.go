@@ -0,0 +1,24 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"shop/migrations"
+	"shop/pkg/config"
+)
+
+// Open connects to the SQL database selected by cfg.Storage and returns the
+// migrations written for its dialect.
+func Open(cfg *config.Config) (*sql.DB, fs.FS, error) {
+	switch cfg.Storage {
+	case config.StorageMySQL:
+		db, err := NewMySQLConnection(cfg.Database)
+		return db, migrations.MySQL, err
+	case config.StorageSQLite:
+		db, err := NewSQLiteConnection(cfg.SQLite)
+		return db, migrations.SQLite, err
+	default:
+		return nil, nil, fmt.Errorf("storage %q is not an SQL database", cfg.Storage)
+	}
+}
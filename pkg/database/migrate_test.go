@@ -0,0 +1,139 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shop/migrations"
+	"shop/pkg/config"
+	"shop/pkg/database"
+)
+
+func TestMigrateSQLiteUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.NewSQLiteConnection(config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "shop.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := func() int {
+		var n int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if err := migrator.EnsureUpToDate(ctx); !errors.Is(err, database.ErrSchemaOutdated) {
+		t.Errorf("EnsureUpToDate on an empty database: err = %v, want %v", err, database.ErrSchemaOutdated)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := migrator.EnsureUpToDate(ctx); err != nil {
+		t.Errorf("EnsureUpToDate after Up: %v", err)
+	}
+	if again, err := migrator.Up(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second Up applied %d migrations (err %v), want none", len(again), err)
+	}
+
+	// Every down migration must undo its up migration, so the schema can be
+	// rolled back completely and rebuilt.
+	rolledBack, err := migrator.Down(ctx, len(applied))
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(rolledBack) != len(applied) {
+		t.Errorf("Down rolled back %d migrations, want %d", len(rolledBack), len(applied))
+	}
+	if n := tables(); n != 0 {
+		t.Errorf("%d tables left after rolling everything back, want 0", n)
+	}
+	if err := migrator.EnsureUpToDate(ctx); !errors.Is(err, database.ErrSchemaOutdated) {
+		t.Errorf("EnsureUpToDate after Down: err = %v, want %v", err, database.ErrSchemaOutdated)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+	if err := migrator.EnsureUpToDate(ctx); err != nil {
+		t.Errorf("EnsureUpToDate after rebuilding: %v", err)
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.NewSQLiteConnection(config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "shop.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	versions := func(name string, m *database.Migrator) map[int64]string {
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		v := make(map[int64]string, len(statuses))
+		for _, s := range statuses {
+			v[s.Version] = s.Name
+		}
+		return v
+	}
+	mysqlMigrator, err := database.NewMigrator(db, migrations.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqliteMigrator, err := database.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mysql, sqlite := versions("mysql", mysqlMigrator), versions("sqlite", sqliteMigrator)
+	for version, name := range mysql {
+		if sqlite[version] != name {
+			t.Errorf("mysql migration %04d_%s has no sqlite counterpart", version, name)
+		}
+	}
+	for version, name := range sqlite {
+		if _, ok := mysql[version]; !ok {
+			t.Errorf("sqlite migration %04d_%s has no mysql counterpart", version, name)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, want := range []string{"0001_add_wishlists", "0002_add_wishlist_items"} {
+		paths, err := database.CreateMigration(dir, want[5:])
+		if err != nil {
+			t.Fatalf("CreateMigration: %v", err)
+		}
+		if len(paths) != 2 || filepath.Base(paths[0]) != want+".up.sql" || filepath.Base(paths[1]) != want+".down.sql" {
+			t.Errorf("CreateMigration wrote %v, want the %s pair", paths, want)
+		}
+	}
+	if _, err := database.CreateMigration(dir, "drop table"); err == nil {
+		t.Error("CreateMigration accepted a name with a space")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("dir holds %d files, want 4", len(entries))
+	}
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"shop/pkg/config"
+
+	_ "modernc.org/sqlite"
+)
+
+func NewSQLiteConnection(cfg config.SQLiteConfig) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	// Store times as "2006-01-02 15:04:05.999999999-07:00", which SQLite's
+	// date functions understand, instead of Go's time.String format.
+	params.Add("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection keeps transactions from
+	// failing with SQLITE_BUSY instead of waiting for each other.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+
+	return db, nil
+}
@@ -6,7 +6,17 @@ import (
     "github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key") // In production, use environment variable
+var (
+    jwtSecret []byte
+    tokenTTL  = 24 * time.Hour
+)
+
+// Configure sets the signing secret and token lifetime. It must be called
+// before tokens are generated or validated.
+func Configure(secret string, ttl time.Duration) {
+    jwtSecret = []byte(secret)
+    tokenTTL = ttl
+}
 
 type Claims struct {
     UserID uint   `json:"user_id"`
@@ -21,7 +31,7 @@ func GenerateToken(userID uint, email, role string) (string, error) {
         Email:  email,
         Role:   role,
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+            ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenTTL)),
             IssuedAt:  jwt.NewNumericDate(time.Now()),
         },
     }